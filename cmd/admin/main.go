@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/auth"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/brokeradmin"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: admin [flags] <command> [args]")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  provision <username> [password]   create a broker user scoped to one player")
+	fmt.Fprintln(os.Stderr, "  deprovision <username>            delete a player's broker user")
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	managementURL := flag.String("url", envOr("PERIL_MANAGEMENT_URL", brokeradmin.DefaultManagementURL), "RabbitMQ management API URL")
+	adminUser := flag.String("user", envOr("PERIL_MANAGEMENT_USER", "guest"), "management API user")
+	adminPassword := flag.String("password", envOr("PERIL_MANAGEMENT_PASSWORD", "guest"), "management API password")
+	vhost := flag.String("vhost", envOr("PERIL_VHOST", "/"), "virtual host the player connects to")
+	flag.Usage = usage
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	username := args[1]
+	if err := auth.ValidateUsername(username); err != nil {
+		log.Fatal(err)
+	}
+
+	client := brokeradmin.NewClient(*managementURL, *adminUser, *adminPassword)
+
+	switch args[0] {
+	case "provision":
+		password := ""
+		if len(args) > 2 {
+			password = args[2]
+		} else {
+			password = generatePassword()
+		}
+
+		if err := client.ProvisionPlayer(*vhost, username, password); err != nil {
+			log.Fatalf("Failed to provision %s: %s\n", username, err)
+		}
+		fmt.Printf("Provisioned broker user %s\n", username)
+		if len(args) == 2 {
+			fmt.Printf("Generated password: %s\n", password)
+		}
+
+	case "deprovision":
+		if err := client.DeprovisionPlayer(username); err != nil {
+			log.Fatalf("Failed to deprovision %s: %s\n", username, err)
+		}
+		fmt.Printf("Deprovisioned broker user %s\n", username)
+
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func generatePassword() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatalf("Failed to generate password: %s\n", err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/bot"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/config"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/player"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	fmt.Println("Starting Peril bot...")
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found")
+	}
+
+	cfg, err := config.Load(config.RoleBot, "bot", os.Args[1:])
+	if config.IsHelp(err) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %s\n", err)
+	}
+	if cfg.Client.Username == "" || cfg.Client.Password == "" {
+		log.Fatal("A bot needs -username and -password\n")
+	}
+	strategy, err := bot.ByName(cfg.Bot.Strategy)
+	if err != nil {
+		log.Fatalf("Failed to pick a strategy: %s\n", err)
+	}
+	seed := cfg.Bot.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	cfg.Apply()
+
+	conn, err := cfg.Broker.Dial()
+	if err != nil {
+		log.Fatalf("Failed to connect to RabbitMQ: %s\n", err)
+	}
+	defer conn.Close()
+
+	publisher, err := pubsub.NewPublisher(conn, pubsub.PublisherConfig{Policy: pubsub.FailFast})
+	if err != nil {
+		log.Fatalf("Failed to open a channel: %s\n", err)
+	}
+	defer publisher.Close()
+
+	username := cfg.Client.Username
+	token, err := player.Login(conn, username, cfg.Client.Password)
+	if err != nil {
+		log.Fatalf("Failed to log in: %s\n", err)
+	}
+	defer func() {
+		if err := player.Logout(conn, username, token); err != nil {
+			log.Printf("Failed to log out: %s\n", err)
+		}
+	}()
+
+	gameState := gamelogic.NewGameState(username)
+	world, err := player.FetchMap(conn)
+	if err != nil {
+		log.Fatalf("Failed to join the game: %s\n", err)
+	}
+	gameState.SetMap(world)
+	army, err := player.FetchArmy(conn, username, token)
+	if err != nil {
+		log.Fatalf("Failed to join the game: %s\n", err)
+	}
+	gameState.SeedUnits(army)
+	gameState.ApproveSpawn = player.SpawnApprover(conn, gameState, token)
+	b := bot.New(gameState, publisher, token, strategy, seed)
+	if gamelogic.TurnMode != gamelogic.TurnsOff {
+		b.Order = func(move gamelogic.ArmyMove) error {
+			return player.SubmitOrder(conn, username, token, gameState.Turn().Number, move)
+		}
+	}
+	err = player.Join(conn, gameState, publisher, token, player.Options{OnMove: b.Observe})
+	if err != nil {
+		log.Fatalf("Failed to join the game: %s\n", err)
+	}
+
+	fmt.Printf("%s is playing %s (seed %d), press Ctrl+C to stop\n", username, strategy.Name(), seed)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	b.Run(ctx, cfg.Bot.Interval)
+	fmt.Println("Bot stopped")
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/player"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// login returns the username with its session token. Given a username it
+// tries those credentials once, otherwise it prompts until the server
+// accepts them.
+func login(conn *amqp.Connection, username, password string) (string, string, error) {
+	if username != "" {
+		token, err := player.Login(conn, username, password)
+		return username, token, err
+	}
+
+	for {
+		username, password, err := gamelogic.ClientLogin()
+		if err != nil {
+			return "", "", err
+		}
+
+		token, err := player.Login(conn, username, password)
+		if errors.Is(err, player.ErrLoginRejected) {
+			fmt.Printf("Login failed: %s\n", err)
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		return username, token, nil
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/outbox"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/player"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/ratelimit"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// errQuit is returned by runCommand when the player quits
+var errQuit = errors.New("quit")
+
+// client is everything a command needs, shared by the prompt and scripts
+type client struct {
+	conn             *amqp.Connection
+	confirmPublisher *pubsub.Publisher
+	gameState        *gamelogic.GameState
+	moveOutbox       *outbox.Outbox
+	logCodec         pubsub.Codec
+	logLimiter       *ratelimit.Bucket
+	moveLimiter      *ratelimit.Bucket
+	token            string
+	// save writes the game state after anything that may have changed it
+	save func()
+}
+
+// runCommand runs one game command. Failures are returned rather than
+// printed so scripts can stop on them.
+func (c *client) runCommand(words []string) error {
+	command := strings.ToLower(words[0])
+	defer c.save()
+
+	switch command {
+	case "spawn":
+		if len(words) != 3 {
+			return errors.New("usage: spawn <location> <unit_type>")
+		}
+		id, err := c.gameState.CommandSpawn(words)
+		if err != nil {
+			return fmt.Errorf("failed to spawn unit: %w", err)
+		}
+		fmt.Printf("Unit spawned with ID: %d\n", id)
+
+	case "move":
+		if len(words) < 3 {
+			return errors.New("usage: move <location> <unit_id>...")
+		}
+
+		before := c.gameState.GetPlayerSnap()
+		move, err := c.gameState.CommandMove(words)
+		if err != nil {
+			return fmt.Errorf("failed to move units: %w", err)
+		}
+
+		// Only moves that would go out use up the limit
+		if !c.moveLimiter.Allow() {
+			c.gameState.RollbackMove(move, gamelogic.UnitsBefore(before, move))
+			return errors.New("you are moving too fast, slow down")
+		}
+
+		// In turn mode the server collects the move and sends it to everyone
+		// when the turn ends
+		if gamelogic.TurnMode != gamelogic.TurnsOff {
+			turn := c.gameState.Turn().Number
+			if err := player.SubmitOrder(c.conn, c.gameState.GetUsername(), c.token, turn, move); err != nil {
+				c.gameState.RollbackMove(move, gamelogic.UnitsBefore(before, move))
+				return fmt.Errorf("failed to give order: %w", err)
+			}
+			fmt.Printf("Order to move to %s accepted for turn %d\n", move.ToLocation, turn)
+			return nil
+		}
+
+		// The move only sticks once the broker confirms it, see newMoveOutbox
+		undo := moveUndo{Move: move, Previous: gamelogic.UnitsBefore(before, move)}
+		_, err = outbox.Enqueue(c.moveOutbox, routing.ExchangePerilTopic, player.MoveKey(c.gameState.GetUsername()), pubsub.JSONCodec{}, move, undo)
+		if err != nil {
+			c.gameState.RollbackMove(undo.Move, undo.Previous)
+			return fmt.Errorf("failed to queue move: %w", err)
+		}
+
+		fmt.Printf("Units moved to %s, waiting for the broker to confirm\n", move.ToLocation)
+
+	case "status":
+		c.gameState.CommandStatus()
+		if pending := c.moveOutbox.Pending(); pending > 0 {
+			fmt.Printf("%d move(s) waiting for broker confirmation\n", pending)
+		}
+
+	case "map":
+		c.gameState.CommandMap()
+
+	case "help":
+		gamelogic.PrintClientHelp()
+
+	case "spam":
+		if len(words) != 2 {
+			return errors.New("usage: spam <count>")
+		}
+
+		count, err := strconv.Atoi(words[1])
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", words[1], err)
+		}
+
+		dropped := 0
+		key := fmt.Sprintf("%s.%s", routing.GameLogSlug, c.gameState.GetUsername())
+		batch := &pubsub.Batch{}
+		for i := 0; i < count; i++ {
+			if !c.logLimiter.Allow() {
+				dropped++
+				continue
+			}
+
+			logEntry := routing.GameLog{
+				CurrentTime: time.Now(),
+				Message:     gamelogic.GetMaliciousLog(),
+				Username:    c.gameState.GetUsername(),
+			}
+			if err := batch.Add(routing.ExchangePerilTopic, key, c.logCodec, logEntry); err != nil {
+				log.Printf("Failed to encode log: %v\n", err)
+			}
+		}
+		if dropped > 0 {
+			fmt.Printf("Rate limit: dropped %d of %d logs (%d dropped this session)\n", dropped, count, c.logLimiter.Dropped())
+		}
+
+		results := pubsub.PublishBatch(context.Background(), c.confirmPublisher, batch)
+		if failed, err := pubsub.CountFailed(results); failed > 0 {
+			return fmt.Errorf("failed to publish %d of %d logs to exchange: %s key: %s: %w", failed, len(results), routing.ExchangePerilTopic, key, err)
+		}
+
+	case "quit":
+		return errQuit
+
+	default:
+		return errors.New("unknown command, type 'help' for available commands")
+	}
+	return nil
+}
+
+// quit logs the player out before the client exits
+func (c *client) quit() {
+	if err := player.Logout(c.conn, c.gameState.GetUsername(), c.token); err != nil {
+		log.Printf("Failed to log out: %s\n", err)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/config"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+// publisherConfig sets how publishes behave while the broker is blocked
+func publisherConfig(cfg config.Client) pubsub.PublisherConfig {
+	policy := pubsub.FailFast
+	if cfg.BlockedPolicy == config.BlockedPolicyBuffer {
+		policy = pubsub.BufferWhenBlocked
+	}
+	return pubsub.PublisherConfig{
+		Policy:     policy,
+		BufferSize: cfg.PublishBuffer,
+		OnBlocked:  printBlocked,
+		OnFailed:   printFailed,
+	}
+}
+
+func printBlocked(blocked bool, reason string) {
+	defer fmt.Print("> ")
+
+	fmt.Println()
+	if blocked {
+		fmt.Printf("WARNING: the broker is blocking publishes: %s\n", reason)
+		return
+	}
+	fmt.Println("The broker is accepting publishes again.")
+}
+
+func printFailed(_, key string, err error) {
+	defer fmt.Print("> ")
+	fmt.Printf("\nWARNING: a message held while the broker was blocked was lost (%s): %v\n", key, err)
+}
@@ -1,209 +1,166 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
-	"strconv"
-	"strings"
-	"time"
 
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/config"
 	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/player"
 	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
-	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/ratelimit"
 	"github.com/joho/godotenv"
-	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run plays the game and returns the exit status, which is only non-zero
+// when a script fails
+func run() int {
 	fmt.Println("Starting Peril client...")
 
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: .env file not found")
 	}
 
-	connectionString := godotenv.Get("RABBITMQ_URL")
-	if connectionString == "" {
-		log.Fatal("Failed to get environment variable RABBITMQ_URL: \n")
+	cfg, err := config.Load(config.RoleClient, "client", os.Args[1:])
+	if config.IsHelp(err) {
+		return 0
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %s\n", err)
+	}
+	cfg.Apply()
+
+	var script []scriptLine
+	if cfg.Client.Script != "" || cfg.Client.Exec != "" {
+		script, err = loadScript(cfg.Client.Script, cfg.Client.Exec)
+		if err != nil {
+			log.Fatalf("Failed to load script: %s\n", err)
+		}
 	}
 
-	conn, err := amqp.Dial(connectionString)
+	conn, err := cfg.Broker.Dial()
 	if err != nil {
 		log.Fatalf("Failed to connect to RabbitMQ: %s\n", err)
 	}
 	defer conn.Close()
 
-	channel, err := conn.Channel()
+	publisher, err := pubsub.NewPublisher(conn, publisherConfig(cfg.Client))
 	if err != nil {
 		log.Fatalf("Failed to open a channel: %s\n", err)
 	}
 
-	defer channel.Close()
+	defer publisher.Close()
 
 	fmt.Println("Connected to RabbitMQ")
 
-	username, err := gamelogic.ClientWelcome()
-
+	username, token, err := login(conn, cfg.Client.Username, cfg.Client.Password)
 	if err != nil {
-		log.Fatalf("Failed to welcome client: %s\n", err)
+		log.Fatalf("Failed to log in: %s\n", err)
 	}
 	fmt.Printf("Welcome, %s!\n", username)
+	if script == nil {
+		gamelogic.PrintClientHelp()
+	}
 
-	queueName := fmt.Sprintf("%s.%s", routing.PauseKey, username)
-	_, _, err = pubsub.DeclareAndBind(
-		conn,
-		routing.ExchangePerilDirect,
-		queueName,
-		routing.PauseKey,
-		pubsub.Transient,
-	)
+	// The saved game carries the unit ID allocator across restarts, and the
+	// server's board catches it up if the save was lost, so new units never
+	// reuse the IDs of units still on the board
+	stateFile := fmt.Sprintf("state.%s.json", username)
+	moveStore, err := openMoveStore(username)
 	if err != nil {
-		log.Fatalf("Failed to declare and bind queue %s: %s\n", queueName, err)
+		log.Fatalf("Failed to open outbox: %s\n", err)
 	}
-
-	fmt.Printf("Queue %s declared and bound\n", queueName)
-
-	armyMovesRoutingKey := fmt.Sprintf("%s.*", routing.ArmyMovesPrefix)
-	armyMovesQueue := fmt.Sprintf("%s.%s", routing.ArmyMovesPrefix, username)
-	armyQueuesRoutingKey := armyMovesQueue
-
-	_, _, err = pubsub.DeclareAndBind(
-		conn,
-		routing.ExchangePerilTopic,
-		armyMovesQueue,
-		armyMovesRoutingKey,
-		pubsub.Transient,
-	)
+	gameState, err := loadGame(stateFile, moveStore, username)
 	if err != nil {
-		log.Fatalf("Failed to declare and bind queue %s: %s\n", armyMovesQueue, err)
+		log.Fatalf("Failed to load game state: %s\n", err)
 	}
-
-	fmt.Printf("Queue %s declared and bound\n", armyMovesQueue)
-
-	warQueueRoutingKey := fmt.Sprintf("%s.*", routing.WarRecognitionsPrefix)
-	warSubscriptionRoutingKey := fmt.Sprintf("%s.%s", routing.WarRecognitionsPrefix, username)
-	_, _, err = pubsub.DeclareAndBind(
-		conn,
-		routing.ExchangePerilTopic,
-		routing.WarRecognitionsPrefix,
-		warQueueRoutingKey,
-		pubsub.Durable,
-	)
+	world, err := player.FetchMap(conn)
 	if err != nil {
-		log.Fatalf("Failed to declare and bind queue %s: %s\n", routing.WarRecognitionsPrefix, err)
+		log.Fatalf("Failed to join the game: %s\n", err)
 	}
-
-	fmt.Printf("Queue %s declared and bound\n", routing.WarRecognitionsPrefix)
-
-	// Subscribe to war queue (durable, shared by all clients)
-
-	// Create new game state
-	gameState := gamelogic.NewGameState(username)
-	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilDirect, queueName, routing.PauseKey, pubsub.Transient, handlerPause(gameState))
+	gameState.SetMap(world)
+	army, err := player.FetchArmy(conn, username, token)
 	if err != nil {
-		log.Fatalf("Failed to subscribe to queue: %s\n", err)
+		log.Fatalf("Failed to join the game: %s\n", err)
+	}
+	gameState.SeedUnits(army)
+	gameState.ApproveSpawn = player.SpawnApprover(conn, gameState, token)
+	saveState := func() {
+		if err := gameState.Save(stateFile); err != nil {
+			log.Printf("Failed to save game state: %s\n", err)
+		}
 	}
 
-	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilTopic, armyMovesQueue, armyMovesRoutingKey, pubsub.Transient, handlerMove(gameState, channel))
+	err = player.Join(conn, gameState, publisher, token, player.Options{
+		AfterHandle: func() {
+			saveState()
+			fmt.Print("> ")
+		},
+	})
 	if err != nil {
-		log.Fatalf("Failed to subscribe to queue: %s\n", err)
+		log.Fatalf("Failed to join the game: %s\n", err)
 	}
 
-	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilTopic, routing.WarRecognitionsPrefix, warSubscriptionRoutingKey, pubsub.Durable, handlerWar(gameState, channel))
+	confirmPublisher, err := pubsub.NewPublisher(conn, pubsub.PublisherConfig{Confirm: true})
 	if err != nil {
-		log.Fatalf("Failed to subscribe to war queue: %s\n", err)
+		log.Fatalf("Failed to open a channel: %s\n", err)
+	}
+	defer confirmPublisher.Close()
+
+	moveOutbox := newMoveOutbox(moveStore, confirmPublisher, gameState, token, saveState)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go moveOutbox.Run(ctx)
+
+	c := &client{
+		conn:             conn,
+		confirmPublisher: confirmPublisher,
+		gameState:        gameState,
+		moveOutbox:       moveOutbox,
+		logCodec:         player.LogCodec(token),
+		logLimiter:       ratelimit.NewBucket(cfg.Client.LogRate, cfg.Client.LogBurst),
+		moveLimiter:      ratelimit.NewBucket(cfg.Client.MoveRate, cfg.Client.MoveBurst),
+		token:            token,
+		save:             saveState,
+	}
+
+	if script != nil {
+		err := runScript(c, script, cfg.Client.ScriptTimeout)
+		c.quit()
+		if err != nil {
+			fmt.Printf("Script failed: %s\n", err)
+			return 1
+		}
+		fmt.Println("Script passed")
+		return 0
 	}
 
 	// REPL loop
 	for {
+		if blocked, reason := publisher.Blocked(); blocked {
+			fmt.Printf("WARNING: the broker is blocking publishes (%s), %d message(s) waiting\n", reason, publisher.Pending())
+		}
+
 		words := gamelogic.GetInput()
 		if len(words) == 0 {
 			continue
 		}
 
-		command := strings.ToLower(words[0])
-
-		switch command {
-		case "spawn":
-			if len(words) != 3 {
-				fmt.Println("Usage: spawn <location> <unit_type>")
-				continue
-			}
-			id, err := gameState.CommandSpawn(words)
-
-			if err != nil {
-				fmt.Printf("Error spawning unit: %s\n", err)
-				continue
-			}
-			fmt.Printf("Unit spawned with ID: %d\n", id)
-
-		case "move":
-			if len(words) != 3 {
-				fmt.Println("Usage: move <location> <unit_id>")
-				continue
-			}
-
-			move, err := gameState.CommandMove(words)
-			if err != nil {
-				fmt.Printf("Error moving unit: %s\n", err)
-				continue
-			}
-			fmt.Printf("Units moved to %s\n", move.ToLocation)
-
-			err = pubsub.PublishJSON(channel, routing.ExchangePerilTopic, armyQueuesRoutingKey, move)
-			if err != nil {
-				log.Fatalf("Failed to publish to exchange %s: %s\n", routing.ExchangePerilTopic, err)
-			}
-
-			fmt.Printf("Moves published to %s\n", armyMovesQueue)
-
-		case "status":
-			gameState.CommandStatus()
-
-		case "help":
-			gamelogic.PrintClientHelp()
-
-		case "spam":
-			if len(words) != 2 {
-				fmt.Println("Usage: spam <count>")
-				continue
-			}
-
-			count, err := strconv.Atoi(words[1])
-
-			if err != nil {
-				fmt.Printf("Failed to parse %s, error: %v", words[1], err)
-				continue
-			}
-
-			for i := 0; i < count; i++ {
-				msg := gamelogic.GetMaliciousLog()
-				key := fmt.Sprintf("%s.%s", routing.GameLogSlug, username)
-
-				logEntry := routing.GameLog{
-					CurrentTime: time.Now(),
-					Message:     msg,
-					Username:    gameState.Player.Username,
-				}
-
-				err := pubsub.PublishGob(
-					channel,
-					routing.ExchangePerilTopic,
-					key,
-					logEntry,
-				)
-
-				if err != nil {
-					log.Printf("Failed to publish log exchange: %s key: %s: %v\n", routing.ExchangePerilTopic, key, err)
-				}
-			}
-
-		case "quit":
+		err := c.runCommand(words)
+		if errors.Is(err, errQuit) {
+			c.quit()
 			gamelogic.PrintQuit()
-			return
-
-		default:
-			fmt.Println("Unknown command. Type 'help' for available commands.")
+			return 0
+		}
+		if err != nil {
+			fmt.Printf("Error: %s\n", err)
 		}
 	}
 }
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/outbox"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/player"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+// moveUndo is stored next to every pending move so it can be rolled back
+type moveUndo struct {
+	Move     gamelogic.ArmyMove
+	Previous []gamelogic.Unit
+}
+
+func openMoveStore(username string) (*outbox.Store, error) {
+	return outbox.OpenStore(fmt.Sprintf("outbox.%s.json", username))
+}
+
+// loadGame loads the saved game, unless the outbox holds a newer snapshot.
+// That happens when the client stopped after queueing a move but before
+// saving the game, and the queued move would otherwise go out for units
+// the game has not moved.
+func loadGame(stateFile string, store *outbox.Store, username string) (*gamelogic.GameState, error) {
+	gs, err := gamelogic.LoadGameState(stateFile, username)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := store.State()
+	if snapshot == nil {
+		return gs, nil
+	}
+	queued, err := gamelogic.RestoreGameState(snapshot, username)
+	if err != nil {
+		return nil, fmt.Errorf("outbox snapshot: %w", err)
+	}
+	if queued.Revision() > gs.Revision() {
+		fmt.Println("Restored your game from the move outbox, which was saved last")
+		return queued, nil
+	}
+	return gs, nil
+}
+
+func newMoveOutbox(store *outbox.Store, publisher *pubsub.Publisher, gs *gamelogic.GameState, token string, save func()) *outbox.Outbox {
+	return outbox.New(store, publisher, outbox.Config{
+		Headers:  player.SessionHeaders(token),
+		Snapshot: gs.Snapshot,
+		OnCommit: func(e outbox.Entry) {
+			defer fmt.Print("> ")
+			var undo moveUndo
+			if err := json.Unmarshal(e.Undo, &undo); err != nil {
+				return
+			}
+			fmt.Printf("\nMove to %s confirmed by the broker\n", undo.Move.ToLocation)
+		},
+		OnRollback: func(e outbox.Entry, err error) {
+			defer fmt.Print("> ")
+			var undo moveUndo
+			if jsonErr := json.Unmarshal(e.Undo, &undo); jsonErr != nil {
+				fmt.Printf("\nFailed to roll back outbox entry %d: %v\n", e.ID, jsonErr)
+				return
+			}
+			gs.RollbackMove(undo.Move, undo.Previous)
+			save()
+			fmt.Printf("\nMove to %s was rejected by the broker (%v), your units have been returned\n", undo.Move.ToLocation, err)
+		},
+		OnRetry: func(e outbox.Entry, attempt int, err error) {
+			if attempt > 1 {
+				return
+			}
+			defer fmt.Print("> ")
+			fmt.Printf("\nMove %d is not confirmed yet (%v), retrying until the broker answers\n", e.ID, err)
+		},
+	})
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// scriptPollInterval is how often wait re-checks its condition
+const scriptPollInterval = 100 * time.Millisecond
+
+// scriptLine is one command of a script with where it came from
+type scriptLine struct {
+	number int
+	words  []string
+}
+
+// parseScript splits a script into commands. Commands are separated by
+// newlines or semicolons, and # starts a comment.
+func parseScript(text string) []scriptLine {
+	lines := []scriptLine{}
+	for i, line := range strings.Split(text, "\n") {
+		if hash := strings.Index(line, "#"); hash >= 0 {
+			line = line[:hash]
+		}
+		for _, command := range strings.Split(line, ";") {
+			words := strings.Fields(command)
+			if len(words) == 0 {
+				continue
+			}
+			lines = append(lines, scriptLine{number: i + 1, words: words})
+		}
+	}
+	return lines
+}
+
+// loadScript returns the commands from -script or -exec
+func loadScript(path, exec string) ([]scriptLine, error) {
+	if path == "" {
+		return parseScript(exec), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read script: %w", err)
+	}
+	return parseScript(string(data)), nil
+}
+
+// runScript runs lines in order and stops at the first command or
+// expectation that fails. Besides the game commands it understands:
+//
+//	wait <duration>         sleep, e.g. wait 2s
+//	wait <condition>        block until the condition holds or timeout passes
+//	expect <condition>      fail unless the condition holds now
+//
+// Conditions are units <n>, units <location> <n>, pending <n>, paused and
+// running.
+func runScript(c *client, lines []scriptLine, timeout time.Duration) error {
+	for _, line := range lines {
+		fmt.Printf("> %s\n", strings.Join(line.words, " "))
+
+		var err error
+		switch strings.ToLower(line.words[0]) {
+		case "wait":
+			err = c.scriptWait(line.words[1:], timeout)
+		case "expect":
+			err = c.scriptExpect(line.words[1:])
+		default:
+			err = c.runCommand(line.words)
+		}
+		if errors.Is(err, errQuit) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %s: %w", line.number, strings.Join(line.words, " "), err)
+		}
+	}
+	return nil
+}
+
+func (c *client) scriptWait(args []string, timeout time.Duration) error {
+	if len(args) == 1 {
+		if d, err := time.ParseDuration(args[0]); err == nil {
+			time.Sleep(d)
+			return nil
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := c.scriptExpect(args)
+		if err == nil || !errors.Is(err, errUnmet) {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s: %w", timeout, err)
+		}
+		time.Sleep(scriptPollInterval)
+	}
+}
+
+// errUnmet marks a well formed condition that does not hold
+var errUnmet = errors.New("expectation not met")
+
+func (c *client) scriptExpect(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: expect <condition>")
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "units":
+		player := c.gameState.GetPlayerSnap()
+		switch len(args) {
+		case 2:
+			return expectCount("units", len(player.Units), args[1])
+		case 3:
+			count := 0
+			for _, unit := range player.Units {
+				if unit.Location == gamelogic.Location(args[1]) {
+					count++
+				}
+			}
+			return expectCount("units in "+args[1], count, args[2])
+		}
+		return errors.New("usage: units [location] <n>")
+
+	case "pending":
+		if len(args) != 2 {
+			return errors.New("usage: pending <n>")
+		}
+		return expectCount("pending moves", c.moveOutbox.Pending(), args[1])
+
+	case "paused", "running":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: %s", args[0])
+		}
+		want := strings.ToLower(args[0]) == "paused"
+		if c.gameState.IsPaused() != want {
+			return fmt.Errorf("%w: game is not %s", errUnmet, args[0])
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown condition %q", args[0])
+}
+
+func expectCount(what string, got int, wantArg string) error {
+	want, err := strconv.Atoi(wantArg)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", wantArg, err)
+	}
+	if got != want {
+		return fmt.Errorf("%w: %s is %d, want %d", errUnmet, what, got, want)
+	}
+	return nil
+}
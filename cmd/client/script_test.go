@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/outbox"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestParseScript(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []scriptLine
+	}{
+		{"empty", "", []scriptLine{}},
+		{"one per line", "spawn europe infantry\nmove asia 1", []scriptLine{
+			{1, []string{"spawn", "europe", "infantry"}},
+			{2, []string{"move", "asia", "1"}},
+		}},
+		{"semicolons share a line number", "status; wait 1s ;expect units 1", []scriptLine{
+			{1, []string{"status"}},
+			{1, []string{"wait", "1s"}},
+			{1, []string{"expect", "units", "1"}},
+		}},
+		{"comments and blank lines", "# setup\n\nspawn europe infantry # first\n  ;  \nstatus", []scriptLine{
+			{3, []string{"spawn", "europe", "infantry"}},
+			{5, []string{"status"}},
+		}},
+		{"comment hides a semicolon", "status # ; quit", []scriptLine{
+			{1, []string{"status"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseScript(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("parseScript(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// newScriptClient returns a client with two units in europe and one in
+// asia and an empty move outbox
+func newScriptClient(t *testing.T) *client {
+	t.Helper()
+	store, err := outbox.OpenStore(filepath.Join(t.TempDir(), "outbox.json"))
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	gs := gamelogic.NewGameState("alice")
+	gs.SeedUnits(gamelogic.Player{Units: map[int]gamelogic.Unit{
+		1: {ID: 1, Rank: gamelogic.RankInfantry, Location: "europe"},
+		2: {ID: 2, Rank: gamelogic.RankInfantry, Location: "europe"},
+		3: {ID: 3, Rank: gamelogic.RankCavalry, Location: "asia"},
+	}})
+	return &client{gameState: gs, moveOutbox: outbox.New(store, nil, outbox.Config{})}
+}
+
+func TestScriptExpect(t *testing.T) {
+	tests := []struct {
+		condition string
+		unmet     bool
+		err       string
+	}{
+		{"units 3", false, ""},
+		{"units 2", true, "units is 3, want 2"},
+		{"units europe 2", false, ""},
+		{"units asia 2", true, "units in asia is 1, want 2"},
+		{"units africa 0", false, ""},
+		{"pending 0", false, ""},
+		{"pending 1", true, "pending moves is 0, want 1"},
+		{"running", false, ""},
+		{"paused", true, "game is not paused"},
+		{"", false, "usage: expect <condition>"},
+		{"units", false, "usage: units [location] <n>"},
+		{"units europe 1 2", false, "usage: units [location] <n>"},
+		{"units many", false, "failed to parse many"},
+		{"pending", false, "usage: pending <n>"},
+		{"paused now", false, "usage: paused"},
+		{"victory", false, `unknown condition "victory"`},
+	}
+	c := newScriptClient(t)
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			err := c.scriptExpect(strings.Fields(tt.condition))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("expect %s: %v", tt.condition, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expect %s: %v, want %q", tt.condition, err, tt.err)
+			}
+			// Only a condition that does not hold is worth waiting on
+			if errors.Is(err, errUnmet) != tt.unmet {
+				t.Fatalf("expect %s: errors.Is(%v, errUnmet) = %v", tt.condition, err, !tt.unmet)
+			}
+		})
+	}
+
+	c.gameState.HandlePause(routing.PlayingState{IsPaused: true})
+	if err := c.scriptExpect([]string{"paused"}); err != nil {
+		t.Fatalf("expect paused after a pause: %v", err)
+	}
+}
+
+func TestScriptWait(t *testing.T) {
+	c := newScriptClient(t)
+
+	start := time.Now()
+	if err := c.scriptWait([]string{"50ms"}, time.Second); err != nil {
+		t.Fatalf("wait 50ms: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("wait 50ms returned after %s", elapsed)
+	}
+
+	start = time.Now()
+	err := c.scriptWait([]string{"units", "4"}, 150*time.Millisecond)
+	if !errors.Is(err, errUnmet) || !strings.Contains(err.Error(), "timed out after 150ms") {
+		t.Fatalf("wait for a condition that never holds: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("gave up after %s, before the timeout", elapsed)
+	}
+
+	// A malformed condition fails at once rather than waiting out the timeout
+	start = time.Now()
+	if err := c.scriptWait([]string{"victory"}, time.Minute); err == nil || errors.Is(err, errUnmet) {
+		t.Fatalf("wait victory: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("wait victory took %s", elapsed)
+	}
+
+	// A condition that comes true while waiting ends the wait
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		c.gameState.HandlePause(routing.PlayingState{IsPaused: true})
+	}()
+	if err := c.scriptWait([]string{"paused"}, time.Second); err != nil {
+		t.Fatalf("wait paused: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/auth"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/config"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/joho/godotenv"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func main() {
+	fmt.Println("Starting Peril load test...")
+
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found")
+	}
+
+	cfg, err := config.Load(config.RoleLoadTest, "loadtest", os.Args[1:])
+	if config.IsHelp(err) {
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %s\n", err)
+	}
+	cfg.Apply()
+	lt := cfg.LoadTest
+
+	world, err := gamelogic.LoadMap(cfg.Game.Map)
+	if err != nil {
+		log.Fatalf("Failed to load map: %s\n", err)
+	}
+
+	if lt.Password == "" {
+		log.Fatalf("Set -password: the simulated players log in like any other\n")
+	}
+	width := len(strconv.Itoa(lt.Players - 1))
+	usernames := []string{}
+	for i := 0; i < lt.Players; i++ {
+		usernames = append(usernames, fmt.Sprintf("%s-%0*d", lt.Prefix, width, i))
+	}
+	if lt.UsersFile != "" {
+		if err := addUsers(lt.UsersFile, usernames, lt.Password); err != nil {
+			log.Fatalf("Failed to add players: %s\n", err)
+		}
+		fmt.Printf("Added %d players to %s\n", len(usernames), lt.UsersFile)
+	}
+
+	conns := []*amqp.Connection{}
+	publishers := []*pubsub.Publisher{}
+	for i := 0; i < lt.Connections; i++ {
+		conn, err := cfg.Broker.Dial()
+		if err != nil {
+			log.Fatalf("Failed to connect to RabbitMQ: %s\n", err)
+		}
+		defer conn.Close()
+		publisher, err := pubsub.NewPublisher(conn, pubsub.PublisherConfig{Policy: pubsub.FailFast})
+		if err != nil {
+			log.Fatalf("Failed to open a channel: %s\n", err)
+		}
+		defer publisher.Close()
+		conns = append(conns, conn)
+		publishers = append(publishers, publisher)
+	}
+
+	st := newStats()
+	players := []*simPlayer{}
+	defer func() {
+		for _, p := range players {
+			if err := p.logout(); err != nil {
+				log.Printf("Failed to log out %s: %s\n", p.username, err)
+			}
+		}
+	}()
+	for i, username := range usernames {
+		p, err := newSimPlayer(conns[i%len(conns)], publishers[i%len(publishers)], world, username, lt.Password, int64(i))
+		if err != nil {
+			log.Fatalf("Failed to set up %s: %s\n", username, err)
+		}
+		players = append(players, p)
+		if err := p.consume(st); err != nil {
+			log.Fatalf("Failed to set up %s: %s\n", username, err)
+		}
+	}
+	fmt.Printf("%d players on %d connection(s), %.2f moves and %.2f spawns per player per second, for %s\n",
+		lt.Players, lt.Connections, lt.MoveRate, lt.SpawnRate, lt.Duration)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, lt.Duration)
+	defer cancel()
+
+	start := time.Now()
+	for _, p := range players {
+		go p.play(ctx, lt.MoveRate, lt.SpawnRate, st)
+	}
+
+	ticker := time.NewTicker(lt.SampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// Give in-flight deliveries a moment before reporting
+			time.Sleep(time.Second)
+			sample(players, st, time.Since(start))
+			st.report(lt.Players, time.Since(start))
+			return
+		case <-ticker.C:
+			sample(players, st, time.Since(start))
+		}
+	}
+}
+
+// sample records the depth of every player's queue and prints progress
+func sample(players []*simPlayer, st *stats, elapsed time.Duration) {
+	d := depthSample{elapsed: elapsed}
+	for _, p := range players {
+		depth, err := p.depth()
+		if err != nil {
+			log.Printf("Failed to inspect %s: %s\n", p.queue, err)
+			continue
+		}
+		d.total += depth
+		if depth > d.max {
+			d.max, d.maxName = depth, p.queue
+		}
+	}
+	st.addDepth(d)
+
+	fmt.Printf("%8s  published %d (%d failed)  consumed %d  queued %d\n",
+		elapsed.Round(time.Second), st.published.Load(), st.failed.Load(), st.consumed.Load(), d.total)
+}
+
+// addUsers gives every simulated player an account on the server, which
+// rereads its user file when it changes
+func addUsers(path string, usernames []string, password string) error {
+	users, err := auth.LoadUserStore(path)
+	if err != nil {
+		return err
+	}
+	passwords := map[string]string{}
+	for _, username := range usernames {
+		passwords[username] = password
+	}
+	return users.SetPasswords(passwords)
+}
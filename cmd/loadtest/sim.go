@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/player"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// simPlayer plays the way cmd/client does: it logs in, has the server
+// approve its spawns, publishes moves with its session token and consumes
+// the moves the server relays. It keeps its units itself so thousands of
+// them do not flood the terminal.
+type simPlayer struct {
+	username  string
+	token     string
+	conn      *amqp.Connection
+	publisher *pubsub.Publisher
+	codec     pubsub.Codec
+	ch        *amqp.Channel
+	queue     string
+	rng       *rand.Rand
+	world     *gamelogic.Map
+	player    gamelogic.Player
+	nextID    int
+
+	mu *sync.Mutex
+	// sent is when each of the player's moves waiting to be relayed was
+	// published, by unit and destination. The relay drops the publisher's
+	// headers, so this is how relayed moves are timed.
+	sent map[string]time.Time
+}
+
+func newSimPlayer(conn *amqp.Connection, publisher *pubsub.Publisher, world *gamelogic.Map, username, password string, seed int64) (*simPlayer, error) {
+	token, err := player.Login(conn, username, password)
+	if err != nil {
+		return nil, err
+	}
+	// Start from the army the board already has, so new units never
+	// reuse an ID from an earlier run
+	army, err := player.FetchArmy(conn, username, token)
+	if err != nil {
+		return nil, err
+	}
+	if army.Units == nil {
+		army.Units = map[int]gamelogic.Unit{}
+	}
+	nextID := 0
+	for id := range army.Units {
+		nextID = max(nextID, id)
+	}
+
+	key := routing.VerifiedKey(fmt.Sprintf("%s.*", routing.ArmyMovesPrefix))
+	ch, queue, err := pubsub.DeclareAndBind(conn, routing.ExchangePerilTopic, player.MoveKey(username), key, pubsub.Transient)
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Qos(pubsub.PrefetchCount, 0, false); err != nil {
+		return nil, fmt.Errorf("failed to set prefetch: %w", err)
+	}
+
+	return &simPlayer{
+		username:  username,
+		token:     token,
+		conn:      conn,
+		publisher: publisher,
+		codec:     player.SessionCodec(token),
+		ch:        ch,
+		queue:     queue.Name,
+		rng:       rand.New(rand.NewSource(seed)),
+		world:     world,
+		player:    army,
+		nextID:    nextID,
+		mu:        &sync.Mutex{},
+		sent:      map[string]time.Time{},
+	}, nil
+}
+
+// logout ends the player's session, so the next run can log in again
+func (p *simPlayer) logout() error {
+	return player.Logout(p.conn, p.username, p.token)
+}
+
+// sentKey names a move by its first unit and destination
+func sentKey(id int, to gamelogic.Location) string {
+	return fmt.Sprintf("%d>%s", id, to)
+}
+
+// consume acks every relayed move, and times the player's own, until the
+// channel closes
+func (p *simPlayer) consume(st *stats) error {
+	deliveries, err := p.ch.Consume(p.queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to consume %s: %w", p.queue, err)
+	}
+
+	go func() {
+		for msg := range deliveries {
+			var move gamelogic.ArmyMove
+			if err := (pubsub.JSONCodec{}).Unmarshal(&msg, &move); err != nil {
+				msg.Nack(false, false)
+				continue
+			}
+			if move.Player.Username == p.username && len(move.Units) > 0 {
+				key := sentKey(move.Units[0].ID, move.ToLocation)
+				p.mu.Lock()
+				sent, ok := p.sent[key]
+				delete(p.sent, key)
+				p.mu.Unlock()
+				if ok {
+					st.latencies.add(time.Since(sent))
+				}
+			}
+			st.consumed.Add(1)
+			msg.Ack(false)
+		}
+	}()
+	return nil
+}
+
+// play spawns and moves at the configured rates until ctx is done. Each
+// player starts at a random offset so their publishes are spread out.
+func (p *simPlayer) play(ctx context.Context, moveRate, spawnRate float64, st *stats) {
+	p.spawn(st)
+
+	if moveRate > 0 {
+		offset := time.Duration(p.rng.Int63n(int64(float64(time.Second) / moveRate)))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(offset):
+		}
+	}
+
+	moves, stopMoves := every(moveRate)
+	defer stopMoves()
+	spawns, stopSpawns := every(spawnRate)
+	defer stopSpawns()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-spawns:
+			p.spawn(st)
+		case <-moves:
+			if err := p.move(); err != nil {
+				st.failed.Add(1)
+				continue
+			}
+			st.published.Add(1)
+		}
+	}
+}
+
+// spawn asks the server for a new unit, as the client does before adding one
+func (p *simPlayer) spawn(st *stats) {
+	ranks := gamelogic.Ranks()
+	locations := p.world.Locations()
+	if starts := p.world.StartingPositions(); len(starts) > 0 && len(p.player.Units) == 0 {
+		locations = starts
+	}
+	unit := gamelogic.Unit{
+		ID:       p.nextID + 1,
+		Rank:     ranks[p.rng.Intn(len(ranks))],
+		Location: locations[p.rng.Intn(len(locations))],
+	}
+	unit.HP = gamelogic.MaxHealth(unit.Rank)
+	if _, err := player.RequestSpawn(p.conn, p.username, p.token, unit); err != nil {
+		st.spawnsRefused.Add(1)
+		return
+	}
+	p.nextID = unit.ID
+	p.player.Units[unit.ID] = unit
+	st.spawned.Add(1)
+}
+
+func (p *simPlayer) move() error {
+	ids := []int{}
+	for id := range p.player.Units {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return errors.New("no units to move")
+	}
+	unit := p.player.Units[ids[p.rng.Intn(len(ids))]]
+	options := p.world.Reachable(unit.Location, gamelogic.MoveRange(unit.Rank))
+	if len(options) == 0 {
+		return fmt.Errorf("%s has nowhere to go", unit.Location)
+	}
+	to := options[p.rng.Intn(len(options))]
+	unit.Location = to
+	p.player.Units[unit.ID] = unit
+
+	snap := gamelogic.Player{Username: p.username, Units: make(map[int]gamelogic.Unit, len(p.player.Units))}
+	for id, u := range p.player.Units {
+		snap.Units[id] = u
+	}
+	move := gamelogic.ArmyMove{
+		Player:     snap,
+		Units:      []gamelogic.Unit{unit},
+		ToLocation: to,
+	}
+	p.mu.Lock()
+	p.sent[sentKey(unit.ID, to)] = time.Now()
+	p.mu.Unlock()
+	return pubsub.Publish(p.publisher, routing.ExchangePerilTopic, player.MoveKey(p.username), p.codec, move)
+}
+
+// depth is how many messages are waiting in the player's queue
+func (p *simPlayer) depth() (int, error) {
+	q, err := p.ch.QueueDeclarePassive(p.queue, false, true, true, false, nil)
+	if err != nil {
+		return 0, err
+	}
+	return q.Messages, nil
+}
+
+// every fires rate times per second. A rate of 0 or less never fires.
+func every(rate float64) (<-chan time.Time, func()) {
+	if rate <= 0 {
+		return nil, func() {}
+	}
+	t := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	return t.C, t.Stop
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencySampleSize bounds the memory used for latency percentiles
+const latencySampleSize = 100_000
+
+type depthSample struct {
+	elapsed time.Duration
+	total   int
+	max     int
+	maxName string
+}
+
+type stats struct {
+	published atomic.Int64
+	failed    atomic.Int64
+	consumed  atomic.Int64
+	// spawned and spawnsRefused count the server's answers to spawns
+	spawned       atomic.Int64
+	spawnsRefused atomic.Int64
+	latencies     *reservoir
+
+	mu     *sync.Mutex
+	depths []depthSample
+}
+
+func newStats() *stats {
+	return &stats{
+		latencies: newReservoir(latencySampleSize),
+		mu:        &sync.Mutex{},
+	}
+}
+
+func (s *stats) addDepth(d depthSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.depths = append(s.depths, d)
+}
+
+// report prints the summary once the run is over
+func (s *stats) report(players int, elapsed time.Duration) {
+	seconds := elapsed.Seconds()
+	published, failed, consumed := s.published.Load(), s.failed.Load(), s.consumed.Load()
+
+	fmt.Println()
+	fmt.Println("==== Load test report ====")
+	fmt.Printf("Players:   %d over %s\n", players, elapsed.Round(time.Millisecond))
+	fmt.Printf("Published: %d moves (%.1f/s), %d failed\n", published, float64(published)/seconds, failed)
+	fmt.Printf("Spawned:   %d units, %d refused by the server\n", s.spawned.Load(), s.spawnsRefused.Load())
+	fmt.Printf("Consumed:  %d deliveries (%.1f/s)\n", consumed, float64(consumed)/seconds)
+
+	ps := []float64{50, 90, 99, 99.9}
+	latencies, seen := s.latencies.percentiles(ps)
+	if seen == 0 {
+		fmt.Println("Latency:   none of the players' own moves were relayed")
+	} else {
+		fmt.Printf("Latency:   %d relayed moves", seen)
+		for i, p := range ps {
+			fmt.Printf(", p%g %s", p, latencies[i].Round(time.Microsecond))
+		}
+		fmt.Printf(", max %s\n", latencies[len(ps)].Round(time.Microsecond))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Println("Queue depth over time:")
+	for _, d := range s.depths {
+		fmt.Printf("  %8s  total %7d", d.elapsed.Round(time.Second), d.total)
+		if d.max > 0 {
+			fmt.Printf("  deepest %d (%s)", d.max, d.maxName)
+		}
+		fmt.Println()
+	}
+}
+
+// reservoir keeps a uniform random sample of every latency it is given
+type reservoir struct {
+	mu      *sync.Mutex
+	rng     *rand.Rand
+	size    int
+	seen    int64
+	samples []time.Duration
+}
+
+func newReservoir(size int) *reservoir {
+	return &reservoir{
+		mu:   &sync.Mutex{},
+		rng:  rand.New(rand.NewSource(time.Now().UnixNano())),
+		size: size,
+	}
+}
+
+func (r *reservoir) add(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seen++
+	if len(r.samples) < r.size {
+		r.samples = append(r.samples, d)
+		return
+	}
+	if i := r.rng.Int63n(r.seen); i < int64(r.size) {
+		r.samples[i] = d
+	}
+}
+
+// percentiles returns the latency at each of ps, followed by the maximum,
+// along with how many latencies were recorded
+func (r *reservoir) percentiles(ps []float64) ([]time.Duration, int64) {
+	r.mu.Lock()
+	sorted := append([]time.Duration(nil), r.samples...)
+	seen := r.seen
+	r.mu.Unlock()
+
+	out := make([]time.Duration, len(ps)+1)
+	if len(sorted) == 0 {
+		return out, seen
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	for i, p := range ps {
+		idx := int(p / 100 * float64(len(sorted)-1))
+		out[i] = sorted[idx]
+	}
+	out[len(ps)] = sorted[len(sorted)-1]
+	return out, seen
+}
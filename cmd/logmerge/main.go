@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logsink"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: logmerge [flags] <file or glob>...")
+	fmt.Fprintln(os.Stderr, "Merges JSON-lines game log shards into one file ordered by time.")
+	fmt.Fprintln(os.Stderr, "Example: logmerge -o game.log 'game.*.log*'")
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	output := flag.String("o", "", "write the merged log here instead of stdout")
+	format := flag.String("format", string(logsink.FormatJSONLines), "output format, jsonl or text")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	if *format != string(logsink.FormatJSONLines) && *format != string(logsink.FormatText) {
+		log.Fatalf("Unknown format %q\n", *format)
+	}
+
+	paths := []string{}
+	for _, pattern := range flag.Args() {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Fatalf("Bad pattern %s: %s\n", pattern, err)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		log.Fatal("No files matched\n")
+	}
+
+	records := []logsink.Record{}
+	for _, path := range paths {
+		recs, err := readFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read %s: %s\n", path, err)
+		}
+		records = append(records, recs...)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		if !records[i].CurrentTime.Equal(records[j].CurrentTime) {
+			return records[i].CurrentTime.Before(records[j].CurrentTime)
+		}
+		return records[i].Instance < records[j].Instance
+	})
+
+	out := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Failed to create %s: %s\n", *output, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	w := bufio.NewWriter(out)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if *format == string(logsink.FormatText) {
+			w.WriteString(rec.Text())
+			continue
+		}
+		if err := enc.Encode(rec); err != nil {
+			log.Fatalf("Failed to write record: %s\n", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatalf("Failed to write output: %s\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "Merged %d entries from %d file(s)\n", len(records), len(paths))
+}
+
+func readFile(path string) ([]logsink.Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return logsink.ReadRecords(f)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/auth"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func handlerLogin(users *auth.UserStore, sessions *auth.Sessions, signer *auth.EventSigner, publisher pubsub.Sender) func(routing.LoginRequest) routing.LoginResponse {
+	return func(req routing.LoginRequest) routing.LoginResponse {
+		defer fmt.Print("> ")
+
+		if err := users.Verify(req.Username, req.Password); err != nil {
+			fmt.Printf("Rejected login for %s: %v\n", req.Username, err)
+			return routing.LoginResponse{Error: err.Error()}
+		}
+
+		token, tokenHash, expires, err := sessions.Start(req.Username)
+		if err != nil {
+			fmt.Printf("Rejected login for %s: %v\n", req.Username, err)
+			return routing.LoginResponse{Error: err.Error()}
+		}
+
+		err = publishSessionEvent(publisher, signer, routing.SessionEvent{
+			Username:  req.Username,
+			TokenHash: tokenHash,
+			ExpiresAt: expires,
+			Active:    true,
+		})
+		if err != nil {
+			fmt.Printf("Failed to share session for %s: %v\n", req.Username, err)
+		}
+
+		fmt.Printf("%s logged in\n", req.Username)
+		return routing.LoginResponse{Token: token}
+	}
+}
+
+func handlerLogout(sessions *auth.Sessions, signer *auth.EventSigner, publisher pubsub.Sender) func(routing.LogoutRequest) routing.LogoutResponse {
+	return func(req routing.LogoutRequest) routing.LogoutResponse {
+		defer fmt.Print("> ")
+
+		tokenHash := auth.HashToken(req.Token)
+		if !sessions.End(req.Username, tokenHash) {
+			return routing.LogoutResponse{Error: "no such session"}
+		}
+
+		err := publishSessionEvent(publisher, signer, routing.SessionEvent{
+			Username:  req.Username,
+			TokenHash: tokenHash,
+			Active:    false,
+		})
+		if err != nil {
+			fmt.Printf("Failed to share logout for %s: %v\n", req.Username, err)
+		}
+
+		fmt.Printf("%s logged out\n", req.Username)
+		return routing.LogoutResponse{}
+	}
+}
+
+// handlerSessionEvent keeps this instance's sessions in step with the
+// other servers sharing the login queue. Events not signed by a server are
+// dropped, so players cannot hijack or end each other's sessions.
+func handlerSessionEvent(sessions *auth.Sessions, signer *auth.EventSigner) func(routing.SessionEvent) pubsub.AckType {
+	return func(ev routing.SessionEvent) pubsub.AckType {
+		if err := signer.Verify(ev); err != nil {
+			fmt.Printf("Dropping session event for %s: %v\n", ev.Username, err)
+			return pubsub.NackDiscard
+		}
+
+		if ev.Active {
+			sessions.Adopt(ev.Username, ev.TokenHash, ev.ExpiresAt)
+		} else if ev.TokenHash == "" {
+			sessions.Kick(ev.Username)
+		} else {
+			sessions.End(ev.Username, ev.TokenHash)
+		}
+		return pubsub.Ack
+	}
+}
+
+// sessionFilter drops messages whose routing key names a player that the
+// session token header does not belong to
+func sessionFilter(sessions *auth.Sessions, prefix string) pubsub.Filter {
+	return func(msg *amqp.Delivery) pubsub.AckType {
+		username := usernameFromKey(msg.RoutingKey, prefix)
+		token, _ := msg.Headers[routing.SessionTokenHeader].(string)
+		if !sessions.Validate(username, token) {
+			fmt.Printf("Dropping %s: no valid session for %s\n", msg.RoutingKey, username)
+			return pubsub.NackDiscard
+		}
+		return pubsub.Ack
+	}
+}
+
+func usernameFromKey(key, prefix string) string {
+	return strings.TrimPrefix(key, prefix+".")
+}
+
+func publishSessionEvent(publisher pubsub.Sender, signer *auth.EventSigner, ev routing.SessionEvent) error {
+	key := fmt.Sprintf("%s.%s", routing.SessionsPrefix, ev.Username)
+	return pubsub.PublishJSON(publisher, routing.ExchangePerilTopic, key, signer.Sign(ev))
+}
+
+func commandAddUser(users *auth.UserStore, words []string) error {
+	if len(words) != 3 {
+		return errors.New("usage: adduser <username> <password>")
+	}
+	return users.SetPassword(words[1], words[2])
+}
+
+func commandKick(sessions *auth.Sessions, signer *auth.EventSigner, publisher pubsub.Sender, words []string) error {
+	if len(words) != 2 {
+		return errors.New("usage: kick <username>")
+	}
+	if !sessions.Kick(words[1]) {
+		return fmt.Errorf("%s has no active session", words[1])
+	}
+	return publishSessionEvent(publisher, signer, routing.SessionEvent{
+		Username: words[1],
+		Active:   false,
+	})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/auth"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// referee keeps the board that spawns, moves and war results are checked
+// against, declares the wars moves start, announces who controls what, and
+// saves the board after every change. Only the leader changes the board,
+// but every instance reads the shared file, so an instance reloads it
+// whenever another has saved it since, such as after taking over as leader.
+type referee struct {
+	mu        *sync.Mutex
+	board     *gamelogic.Board
+	path      string
+	modTime   time.Time
+	active    *activeMap
+	publisher pubsub.Sender
+	// econ charges for spawns. It is nil with the economy off.
+	econ *ledger
+}
+
+func newReferee(path string, active *activeMap, publisher pubsub.Sender) (*referee, error) {
+	board, err := gamelogic.LoadBoard(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &referee{mu: &sync.Mutex{}, board: board, path: path, active: active, publisher: publisher}
+	if info, err := os.Stat(path); err == nil {
+		r.modTime = info.ModTime()
+	}
+	return r, nil
+}
+
+// current is the board, reloaded first if the file has changed
+func (r *referee) current() *gamelogic.Board {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	info, err := os.Stat(r.path)
+	if err != nil || info.ModTime().Equal(r.modTime) {
+		return r.board
+	}
+	board, err := gamelogic.LoadBoard(r.path)
+	if err != nil {
+		fmt.Printf("Failed to reload board: %v\n", err)
+		return r.board
+	}
+	r.board, r.modTime = board, info.ModTime()
+	return r.board
+}
+
+// save writes the board out after a change
+func (r *referee) save() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.board.Save(r.path); err != nil {
+		fmt.Printf("Failed to save board: %v\n", err)
+		return
+	}
+	if info, err := os.Stat(r.path); err == nil {
+		r.modTime = info.ModTime()
+	}
+}
+
+// reset clears the board for a new match
+func (r *referee) reset() {
+	r.mu.Lock()
+	r.board = gamelogic.NewBoard()
+	r.mu.Unlock()
+	r.save()
+}
+
+// checkMove makes sure a move is username's own and is one the board
+// allows, and returns it as the board has it
+func (r *referee) checkMove(username string, move gamelogic.ArmyMove) (gamelogic.ArmyMove, error) {
+	if move.Player.Username != username {
+		return gamelogic.ArmyMove{}, fmt.Errorf("move by %s sent as %s", move.Player.Username, username)
+	}
+	return r.current().CheckMove(r.active.get(), move)
+}
+
+// move records a move returned by checkMove and returns the control
+// changes it causes, for the caller to announce
+func (r *referee) move(move gamelogic.ArmyMove) []gamelogic.ControlChange {
+	changes := r.current().Move(move)
+	r.save()
+	return changes
+}
+
+// moveAndDeclare records a move made outside of turns, then declares the
+// wars it starts
+func (r *referee) moveAndDeclare(move gamelogic.ArmyMove) {
+	r.announce(r.move(move))
+	r.declare(move.Player.Username)
+}
+
+// announce tells every player about territories changing hands, on
+// verified.territory.<owner>
+func (r *referee) announce(changes []gamelogic.ControlChange) {
+	for _, change := range changes {
+		key := routing.VerifiedKey(fmt.Sprintf("%s.%s", routing.TerritoryPrefix, change.Owner))
+		if err := pubsub.PublishJSON(r.publisher, routing.ExchangePerilTopic, key, change); err != nil {
+			fmt.Printf("Failed to announce %s: %v\n", change, err)
+		}
+	}
+}
+
+// warTimeout is how long an attacker has to report a war's result before
+// the war expires and the next move there declares it again
+const warTimeout = time.Minute
+
+// declare sends attacker every war its units are now in to fight. A war
+// that cannot be sent is withdrawn, so the next move there declares it
+// again.
+func (r *referee) declare(attacker string) {
+	board := r.current()
+	wars := board.DeclareWars(attacker, time.Now().Add(warTimeout))
+	if len(wars) == 0 {
+		return
+	}
+	for _, rw := range wars {
+		key := routing.VerifiedKey(fmt.Sprintf("%s.%s", routing.WarRecognitionsPrefix, rw.Attacker.Username))
+		if err := pubsub.PublishJSON(r.publisher, routing.ExchangePerilTopic, key, rw); err != nil {
+			fmt.Printf("Failed to declare war in %s: %v\n", rw.Location, err)
+			board.WithdrawWar(rw.Location)
+		}
+	}
+	r.save()
+}
+
+// checkWarResult makes sure a war result comes from the attacker the war
+// was sent to, and returns the result of fighting that war, so an attacker
+// cannot change how its war went
+func (r *referee) checkWarResult(username string, report gamelogic.WarReport) (gamelogic.WarReport, error) {
+	if report.Attacker != username {
+		return gamelogic.WarReport{}, fmt.Errorf("war resolved by %s sent as %s", report.Attacker, username)
+	}
+	rw, ok := r.current().PendingWar(username, report.Location)
+	if !ok {
+		return gamelogic.WarReport{}, fmt.Errorf("%s has no war to fight in %s", username, report.Location)
+	}
+	return gamelogic.ResolveWar(rw)
+}
+
+// warResult records what a war did to the armies on the board
+func (r *referee) warResult(report gamelogic.WarReport) {
+	changes := r.current().ApplyWarReport(report)
+	r.save()
+	r.announce(changes)
+}
+
+// spawn checks a unit against the board, charges for it if the economy
+// is on, then adds it
+func (r *referee) spawn(username string, unit gamelogic.Unit) (gamelogic.Balance, error) {
+	board := r.current()
+	world := r.active.get()
+	if err := board.CheckSpawn(world, username, unit); err != nil {
+		return gamelogic.Balance{}, err
+	}
+	balance := gamelogic.Balance{}
+	if r.econ != nil {
+		held := board.Holdings()[username]
+		first := len(board.Army(username).Units) == 0
+		b, err := r.econ.current().Spawn(world, username, unit, held, first)
+		if err != nil {
+			return b, err
+		}
+		r.econ.save()
+		balance = b
+	}
+	changes := board.Spawn(username, unit)
+	r.save()
+	r.announce(changes)
+	return balance, nil
+}
+
+// handlerArmy tells a joining player what its army is on the board
+func handlerArmy(r *referee, sessions *auth.Sessions) func(gamelogic.ArmyRequest) gamelogic.ArmyResponse {
+	return func(req gamelogic.ArmyRequest) gamelogic.ArmyResponse {
+		if !sessions.Validate(req.Username, req.Token) {
+			return gamelogic.ArmyResponse{Error: fmt.Sprintf("no valid session for %s", req.Username)}
+		}
+		return gamelogic.ArmyResponse{Army: r.current().Army(req.Username)}
+	}
+}
+
+// handlerSpawn approves every unit before a player spawns it
+func handlerSpawn(r *referee, sessions *auth.Sessions) func(gamelogic.SpawnRequest) gamelogic.SpawnResponse {
+	return func(req gamelogic.SpawnRequest) gamelogic.SpawnResponse {
+		if !sessions.Validate(req.Username, req.Token) {
+			return gamelogic.SpawnResponse{Error: fmt.Sprintf("no valid session for %s", req.Username)}
+		}
+		balance, err := r.spawn(req.Username, req.Unit)
+		if err != nil {
+			return gamelogic.SpawnResponse{Error: err.Error(), Balance: balance}
+		}
+		return gamelogic.SpawnResponse{Balance: balance}
+	}
+}
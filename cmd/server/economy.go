@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// ledger is the economy together with where it is kept, the map it is
+// played on and the board that says who holds what. Like the board, the
+// economy file is shared by every server instance, so a ledger reloads it
+// whenever another instance has saved it since.
+type ledger struct {
+	mu        *sync.Mutex
+	economy   *gamelogic.Economy
+	path      string
+	modTime   time.Time
+	active    *activeMap
+	ref       *referee
+	publisher pubsub.Sender
+}
+
+func newLedger(path string, active *activeMap, ref *referee, publisher pubsub.Sender) (*ledger, error) {
+	economy, err := gamelogic.LoadEconomy(path)
+	if err != nil {
+		return nil, err
+	}
+	l := &ledger{mu: &sync.Mutex{}, economy: economy, path: path, active: active, ref: ref, publisher: publisher}
+	if info, err := os.Stat(path); err == nil {
+		l.modTime = info.ModTime()
+	}
+	return l, nil
+}
+
+// current is the economy, reloaded first if the file has changed
+func (l *ledger) current() *gamelogic.Economy {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	info, err := os.Stat(l.path)
+	if err != nil || info.ModTime().Equal(l.modTime) {
+		return l.economy
+	}
+	economy, err := gamelogic.LoadEconomy(l.path)
+	if err != nil {
+		fmt.Printf("Failed to reload economy: %v\n", err)
+		return l.economy
+	}
+	l.economy, l.modTime = economy, info.ModTime()
+	return l.economy
+}
+
+func (l *ledger) save() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.economy.Save(l.path); err != nil {
+		fmt.Printf("Failed to save economy: %v\n", err)
+		return
+	}
+	if info, err := os.Stat(l.path); err == nil {
+		l.modTime = info.ModTime()
+	}
+}
+
+// reset starts every player's resources over for a new match
+func (l *ledger) reset() {
+	l.mu.Lock()
+	l.economy = gamelogic.NewEconomy()
+	l.mu.Unlock()
+	l.save()
+}
+
+// payIncome pays every player and tells them their new balances
+func (l *ledger) payIncome() {
+	world := l.active.get()
+	holdings := l.ref.current().Holdings()
+	economy := l.current()
+	economy.PayIncome(world, holdings)
+	l.save()
+	update := economy.Update(world, holdings)
+	if err := pubsub.PublishJSON(l.publisher, routing.ExchangePerilDirect, routing.EconomyKey, update); err != nil {
+		fmt.Printf("Failed to announce balances: %v\n", err)
+	}
+}
+
+// runIncome pays income every interval, for games without turns
+func (l *ledger) runIncome(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		l.payIncome()
+	}
+}
+
+// endTurn pays for a turn. Its moves are on the board already.
+func (l *ledger) endTurn([]gamelogic.ArmyMove) {
+	l.payIncome()
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logsink"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logstore"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func handlerLog(sink *logsink.Sink, store *logstore.Store) func(routing.GameLog) pubsub.AckType {
+	return func(gl routing.GameLog) pubsub.AckType {
+		defer fmt.Print("> ")
+
+		// Insert ignores logs it already has, so when the sink write fails
+		// and the log is redelivered it is neither stored nor written twice
+		err := store.Insert(gl)
+		if err != nil {
+			fmt.Printf("Failed to store log: %v\n", err)
+			return pubsub.NackRequeue
+		}
+
+		err = sink.Write(gl)
+		if err != nil {
+			fmt.Printf("Failed to write log: %v\n", err)
+			return pubsub.NackRequeue
+		}
+		return pubsub.Ack
+	}
+}
+
+func printBlocked(blocked bool, reason string) {
+	defer fmt.Print("> ")
+
+	fmt.Println()
+	if blocked {
+		fmt.Printf("WARNING: the broker is blocking publishes: %s\n", reason)
+		return
+	}
+	fmt.Println("The broker is accepting publishes again.")
+}
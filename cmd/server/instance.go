@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/config"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logsink"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+// defaultInstanceID names this server when no instance ID is configured
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "server"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// logSetup picks the log file and game_logs queue type for the log mode.
+// In shard mode every instance consumes and writes its own file, named by
+// putting the instance ID before the extension of the configured path, to
+// be merged later with cmd/logmerge. In single mode
+// game_logs is a single-active-consumer queue so only one instance writes
+// the log at a time and the rest stand by.
+func logSetup(cfg config.Config) (logsink.Config, pubsub.SimpleQueueType) {
+	sinkConfig := cfg.SinkConfig()
+	if cfg.Log.Mode == config.LogModeSingle {
+		return sinkConfig, pubsub.DurableSingleActive
+	}
+	sinkConfig.Path = shardPath(sinkConfig.Path, cfg.Server.InstanceID)
+	return sinkConfig, pubsub.Durable
+}
+
+// shardPath turns logs/game.log into logs/game.<instance>.log
+func shardPath(path, instance string) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(path, ext), instance, ext)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/config"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+)
+
+func TestLogSetup(t *testing.T) {
+	tests := []struct {
+		mode      string
+		path      string
+		wantPath  string
+		wantQueue pubsub.SimpleQueueType
+	}{
+		{config.LogModeShard, "game.log", "game.server-0.log", pubsub.Durable},
+		{config.LogModeShard, filepath.Join("logs", "peril.jsonl"), filepath.Join("logs", "peril.server-0.jsonl"), pubsub.Durable},
+		{config.LogModeShard, filepath.Join("logs.d", "game"), filepath.Join("logs.d", "game.server-0"), pubsub.Durable},
+		{config.LogModeSingle, filepath.Join("logs", "peril.jsonl"), filepath.Join("logs", "peril.jsonl"), pubsub.DurableSingleActive},
+	}
+	for _, tt := range tests {
+		cfg := config.Default()
+		cfg.Log.Mode = tt.mode
+		cfg.Log.Path = tt.path
+		cfg.Server.InstanceID = "server-0"
+
+		sinkConfig, queueType := logSetup(cfg)
+		if sinkConfig.Path != tt.wantPath || queueType != tt.wantQueue {
+			t.Errorf("%s mode with %q: got %q and queue %v, want %q and %v", tt.mode, tt.path, sinkConfig.Path, queueType, tt.wantPath, tt.wantQueue)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// leaderTickInterval is how often each instance ticks into the leader
+// queue, and so about how long the next leader takes to notice it leads
+const leaderTickInterval = 2 * time.Second
+
+// leaderTick is what every instance sends to the leader queue
+type leaderTick struct {
+	Instance string
+}
+
+// leader elects the one server instance that owns the game's clocks.
+// Every instance ticks into a single-active queue. The broker hands every
+// tick to the same consumer until it goes away, so the first instance a
+// tick reaches leads for as long as it stays connected, and the next one
+// takes over when it does not.
+type leader struct {
+	instance string
+	once     *sync.Once
+	elected  chan struct{}
+}
+
+func newLeader(instance string) *leader {
+	return &leader{
+		instance: instance,
+		once:     &sync.Once{},
+		elected:  make(chan struct{}),
+	}
+}
+
+// campaign joins the election and keeps ticking until the program exits
+func (l *leader) campaign(conn *amqp.Connection, publisher pubsub.Sender) error {
+	err := pubsub.SubscribeJSON(conn, routing.ExchangePerilDirect, routing.LeaderKey, routing.LeaderKey, pubsub.DurableSingleActive, l.handlerTick())
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", routing.LeaderKey, err)
+	}
+	go func() {
+		for {
+			if err := pubsub.PublishJSON(publisher, routing.ExchangePerilDirect, routing.LeaderKey, leaderTick{Instance: l.instance}); err != nil {
+				fmt.Printf("Failed to tick: %v\n", err)
+			}
+			time.Sleep(leaderTickInterval)
+		}
+	}()
+	return nil
+}
+
+// handlerTick makes this instance the leader on the first tick it gets
+func (l *leader) handlerTick() func(leaderTick) pubsub.AckType {
+	return func(leaderTick) pubsub.AckType {
+		l.once.Do(func() {
+			defer fmt.Print("> ")
+			fmt.Printf("\nInstance %s now leads\n", l.instance)
+			close(l.elected)
+		})
+		return pubsub.Ack
+	}
+}
+
+// leading is true once this instance leads
+func (l *leader) leading() bool {
+	select {
+	case <-l.elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// whenElected runs duty in the background once this instance leads
+func (l *leader) whenElected(duty func()) {
+	go func() {
+		<-l.elected
+		duty()
+	}()
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaderElected(t *testing.T) {
+	l := newLeader("server-0")
+	ran := make(chan struct{})
+	l.whenElected(func() { close(ran) })
+	if l.leading() {
+		t.Fatal("leading before any tick")
+	}
+
+	// Ticks from any instance mean this one is the active consumer
+	tick := l.handlerTick()
+	tick(leaderTick{Instance: "server-1"})
+	tick(leaderTick{Instance: "server-0"})
+	if !l.leading() {
+		t.Fatal("not leading after a tick")
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("the leader's duty did not run")
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/ratelimit"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// rateLimitFilter gives every player its own token bucket, keyed by the
+// username at the end of the routing key. Messages over the limit are
+// dead-lettered rather than requeued.
+func rateLimitFilter(limiter *ratelimit.Keyed, prefix string) pubsub.Filter {
+	return func(msg *amqp.Delivery) pubsub.AckType {
+		if !limiter.Allow(usernameFromKey(msg.RoutingKey, prefix)) {
+			return pubsub.NackDiscard
+		}
+		return pubsub.Ack
+	}
+}
+
+func commandAbuse(logLimiter, moveLimiter *ratelimit.Keyed) {
+	logs, moves := logLimiter.Dropped(), moveLimiter.Dropped()
+	if len(logs) == 0 && len(moves) == 0 {
+		fmt.Println("No player has been rate limited.")
+		return
+	}
+	printDropped("Game logs", logs)
+	printDropped("Moves", moves)
+}
+
+func printDropped(what string, counts []ratelimit.Count) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Printf("%s dropped by the rate limiter:\n", what)
+	for _, c := range counts {
+		fmt.Printf("* %s: %d\n", c.Key, c.Dropped)
+	}
+}
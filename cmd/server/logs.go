@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/logstore"
+)
+
+// commandLogs answers `logs [--user X] [--since T] [--grep text] [--limit n]`.
+// It takes the whole input line so a quoted --grep phrase stays together.
+func commandLogs(store *logstore.Store, line string) error {
+	q, err := parseLogsQuery(line)
+	if err != nil {
+		return err
+	}
+
+	logs, err := store.Find(q)
+	if err != nil {
+		return err
+	}
+	if len(logs) == 0 {
+		fmt.Println("No matching logs.")
+		return nil
+	}
+	// Oldest first reads naturally in a terminal
+	for i := len(logs) - 1; i >= 0; i-- {
+		gl := logs[i]
+		fmt.Printf("%v %v: %v\n", gl.CurrentTime.Format(time.RFC3339), gl.Username, gl.Message)
+	}
+	return nil
+}
+
+func parseLogsQuery(line string) (logstore.Query, error) {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	user := fs.String("user", "", "only logs from this player")
+	since := fs.String("since", "", "RFC3339 time, a duration such as 2h, or today")
+	grep := fs.String("grep", "", "only logs whose message contains this text")
+	limit := fs.Int("limit", logstore.DefaultLimit, "maximum number of logs")
+	words, err := splitArgs(line)
+	if err != nil {
+		return logstore.Query{}, err
+	}
+	if len(words) == 0 {
+		return logstore.Query{}, errors.New("usage: logs [--user X] [--since T] [--grep text] [--limit n]")
+	}
+	if err := fs.Parse(words[1:]); err != nil {
+		return logstore.Query{}, fmt.Errorf("usage: logs [--user X] [--since T] [--grep text] [--limit n]: %w", err)
+	}
+
+	q := logstore.Query{
+		Username: *user,
+		Limit:    *limit,
+		// Words after the flags belong to an unquoted --grep phrase
+		Grep: strings.TrimSpace(strings.Join(append([]string{*grep}, fs.Args()...), " ")),
+	}
+	if *since != "" {
+		t, err := parseSince(*since)
+		if err != nil {
+			return logstore.Query{}, err
+		}
+		q.Since = t
+	}
+	return q, nil
+}
+
+// splitArgs splits line into words like a shell does: on spaces, except
+// inside single or double quotes, which are removed
+func splitArgs(line string) ([]string, error) {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+func parseSince(s string) (time.Time, error) {
+	if s == "today" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()), nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("could not parse --since %q, use RFC3339, YYYY-MM-DD, a duration or today", s)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []string
+		wantErr bool
+	}{
+		{`logs --grep won`, []string{"logs", "--grep", "won"}, false},
+		{`logs --grep "against alice" --since today`, []string{"logs", "--grep", "against alice", "--since", "today"}, false},
+		{`logs --grep 'two  spaces'`, []string{"logs", "--grep", "two  spaces"}, false},
+		{`logs --grep "it's"`, []string{"logs", "--grep", "it's"}, false},
+		{`logs --grep ""`, []string{"logs", "--grep", ""}, false},
+		{`logs   --user    bob  `, []string{"logs", "--user", "bob"}, false},
+		{`logs --grep "open`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := splitArgs(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitArgs(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitArgs(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParseLogsQuery(t *testing.T) {
+	tests := []struct {
+		line      string
+		user      string
+		grep      string
+		limit     int
+		wantSince bool
+		wantErr   bool
+	}{
+		{`logs --grep "against alice" --since today`, "", "against alice", 50, true, false},
+		{`logs --user bob --limit 5`, "bob", "", 5, false, false},
+		{`logs --user bob --grep against alice`, "bob", "against alice", 50, false, false},
+		{`logs --since yesterday-ish`, "", "", 0, false, true},
+		{`logs --limit many`, "", "", 0, false, true},
+	}
+	for _, tt := range tests {
+		q, err := parseLogsQuery(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLogsQuery(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if q.Username != tt.user || q.Grep != tt.grep || q.Limit != tt.limit || q.Since.IsZero() == tt.wantSince {
+			t.Errorf("parseLogsQuery(%q) = %+v", tt.line, q)
+		}
+		if tt.wantSince && q.Since.After(time.Now()) {
+			t.Errorf("parseLogsQuery(%q) since %v is in the future", tt.line, q.Since)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// activeMap is the map the current match is played on
+type activeMap struct {
+	mu    *sync.RWMutex
+	world *gamelogic.Map
+}
+
+func newActiveMap(world *gamelogic.Map) *activeMap {
+	return &activeMap{mu: &sync.RWMutex{}, world: world}
+}
+
+func (a *activeMap) get() *gamelogic.Map {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.world
+}
+
+func (a *activeMap) set(world *gamelogic.Map) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.world = world
+}
+
+func mapResponse(world *gamelogic.Map) (routing.MapResponse, error) {
+	def, err := json.Marshal(world)
+	if err != nil {
+		return routing.MapResponse{}, fmt.Errorf("failed to encode map %s: %w", world.Name, err)
+	}
+	return routing.MapResponse{Name: world.Name, Definition: def}, nil
+}
+
+// handlerMapRequest tells a joining player which map is in play
+func handlerMapRequest(active *activeMap) func(routing.MapRequest) routing.MapResponse {
+	return func(routing.MapRequest) routing.MapResponse {
+		resp, err := mapResponse(active.get())
+		if err != nil {
+			fmt.Println(err)
+		}
+		return resp
+	}
+}
+
+// handlerMapChanged keeps every server instance on the map last announced
+func handlerMapChanged(active *activeMap) func(routing.MapResponse) pubsub.AckType {
+	return func(resp routing.MapResponse) pubsub.AckType {
+		world, err := gamelogic.ParseMap(resp.Definition)
+		if err != nil {
+			fmt.Printf("Ignoring bad map announcement: %v\n", err)
+			return pubsub.NackDiscard
+		}
+		if active.get().Name != world.Name {
+			defer fmt.Print("> ")
+			fmt.Printf("\nSwitched to map %s\n", world.Name)
+		}
+		active.set(world)
+		return pubsub.Ack
+	}
+}
+
+// commandMap shows the active map, or switches every player to another.
+// Maps only change between matches: the switch is refused while any unit
+// is on the board, and clears the territories and resources of the match
+// before.
+func commandMap(active *activeMap, ref *referee, publisher pubsub.Sender, words []string) error {
+	if len(words) == 1 {
+		world := active.get()
+		fmt.Printf("Playing on %s: %d territories, %d regions\n", world.Name, len(world.Locations()), len(world.Regions()))
+		for _, r := range world.Regions() {
+			fmt.Printf("* %s (+%d): %v\n", r.Name, r.Bonus, r.Territories)
+		}
+		return nil
+	}
+	if len(words) != 2 {
+		return errors.New("usage: map [name or file]")
+	}
+
+	if units := ref.current().Units(); units > 0 {
+		return fmt.Errorf("%d unit(s) are still on the board, the map can only change between matches", units)
+	}
+	world, err := gamelogic.LoadMap(words[1])
+	if err != nil {
+		return err
+	}
+	resp, err := mapResponse(world)
+	if err != nil {
+		return err
+	}
+	ref.reset()
+	if ref.econ != nil {
+		ref.econ.reset()
+	}
+	active.set(world)
+	if err := pubsub.PublishJSON(publisher, routing.ExchangePerilDirect, routing.MapChangedKey, resp); err != nil {
+		return fmt.Errorf("failed to announce map: %w", err)
+	}
+	fmt.Printf("Switched to map %s\n", world.Name)
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestCommandMapBetweenMatches(t *testing.T) {
+	sender := &fakeSender{}
+	ref := newTestReferee(t, sender)
+	active := ref.active
+
+	if err := commandMap(active, ref, sender, []string{"map", "risk"}); err == nil {
+		t.Fatal("switched maps with alice's unit on the board")
+	}
+	if active.get().Name != gamelogic.DefaultMap().Name {
+		t.Fatalf("refused switch left the map at %s", active.get().Name)
+	}
+
+	// alice's army is wiped out, but she still holds europe
+	ref.current().ApplyWarReport(gamelogic.WarReport{
+		Location: "europe",
+		Fates:    []gamelogic.UnitFate{{Owner: "alice", Unit: gamelogic.Unit{ID: 1}, Killed: true}},
+	})
+	ref.save()
+	sender.sent = nil
+
+	if err := commandMap(active, ref, sender, []string{"map", "risk"}); err != nil {
+		t.Fatalf("switch with an empty board: %v", err)
+	}
+	if active.get().Name != "risk" {
+		t.Fatalf("map is %s, want risk", active.get().Name)
+	}
+	if held := ref.current().Holdings(); len(held) != 0 {
+		t.Fatalf("the new match starts with holdings %+v", held)
+	}
+	if announced := sender.on(routing.MapChangedKey); len(announced) != 1 {
+		t.Fatalf("announced the map %d times, want once", len(announced))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/auth"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// relay passes the messages players publish under their own routing keys
+// on to the other players, once they are checked
+type relay[T any] struct {
+	prefix string
+	// check returns the message to pass on in place of val, or why it may
+	// not be passed on
+	check func(username string, val T) (T, error)
+	// done, if set, records a message once it has been passed on
+	done func(val T)
+}
+
+// handler republishes checked player messages under the verified prefix,
+// where the players listen. Republishing drops the session token header,
+// so players never see each other's tokens.
+func (r relay[T]) handler(publisher pubsub.Sender) func(string, T) pubsub.AckType {
+	return func(key string, val T) pubsub.AckType {
+		username := usernameFromKey(key, r.prefix)
+		checked, err := r.check(username, val)
+		if err != nil {
+			fmt.Printf("Dropping %s: %v\n", key, err)
+			return pubsub.NackDiscard
+		}
+
+		err = pubsub.PublishJSON(publisher, routing.ExchangePerilTopic, routing.VerifiedKey(key), checked)
+		if err != nil {
+			fmt.Printf("Failed to relay %s: %v\n", key, err)
+			return pubsub.NackRequeue
+		}
+		if r.done != nil {
+			r.done(checked)
+		}
+		return pubsub.Ack
+	}
+}
+
+// subscribe relays prefix.* through a single-active queue shared by every
+// server instance, so each message is relayed once. filters run after the
+// session check.
+func (r relay[T]) subscribe(conn *amqp.Connection, sessions *auth.Sessions, publisher pubsub.Sender, filters ...pubsub.Filter) error {
+	queue := fmt.Sprintf("relay.%s", r.prefix)
+	key := fmt.Sprintf("%s.*", r.prefix)
+	opts := []pubsub.SubscribeOption{pubsub.WithFilter(sessionFilter(sessions, r.prefix))}
+	for _, f := range filters {
+		opts = append(opts, pubsub.WithFilter(f))
+	}
+	err := pubsub.SubscribeWithKey(conn, routing.ExchangePerilTopic, queue, key, pubsub.DurableSingleActive, pubsub.JSONCodec{}, r.handler(publisher), opts...)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", queue, err)
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type published struct {
+	key string
+	msg amqp.Publishing
+}
+
+type fakeSender struct {
+	sent []published
+	err  error
+}
+
+func (f *fakeSender) PublishWithContext(_ context.Context, _, key string, _, _ bool, msg amqp.Publishing) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, published{key: key, msg: msg})
+	return nil
+}
+
+// on lists the messages sent with keys starting with prefix
+func (f *fakeSender) on(prefix string) []published {
+	matched := []published{}
+	for _, p := range f.sent {
+		if strings.HasPrefix(p.key, prefix) {
+			matched = append(matched, p)
+		}
+	}
+	return matched
+}
+
+// newTestReferee is a referee on the default map whose board is saved
+// in a temporary directory, with alice's infantry 1 in europe. It declares
+// wars through sender.
+func newTestReferee(t *testing.T, sender pubsub.Sender) *referee {
+	t.Helper()
+	ref, err := newReferee(filepath.Join(t.TempDir(), "board.json"), newActiveMap(gamelogic.DefaultMap()), sender)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unit := gamelogic.Unit{ID: 1, Rank: gamelogic.RankInfantry, Location: "europe"}
+	if _, err := ref.spawn("alice", unit); err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func TestRelayMoves(t *testing.T) {
+	europe := gamelogic.Unit{ID: 1, Rank: gamelogic.RankInfantry, Location: "europe"}
+	tests := []struct {
+		name    string
+		key     string
+		move    gamelogic.ArmyMove
+		sendErr error
+		want    pubsub.AckType
+		wantKey string
+	}{
+		{
+			name:    "own move",
+			key:     "army_moves.alice",
+			move:    gamelogic.ArmyMove{Player: gamelogic.Player{Username: "alice"}, Units: []gamelogic.Unit{europe}, ToLocation: "africa"},
+			want:    pubsub.Ack,
+			wantKey: "verified.army_moves.alice",
+		},
+		{
+			name: "someone else's move",
+			key:  "army_moves.bob",
+			move: gamelogic.ArmyMove{Player: gamelogic.Player{Username: "alice"}, Units: []gamelogic.Unit{europe}, ToLocation: "africa"},
+			want: pubsub.NackDiscard,
+		},
+		{
+			name: "unit not on the board",
+			key:  "army_moves.alice",
+			move: gamelogic.ArmyMove{Player: gamelogic.Player{Username: "alice"}, Units: []gamelogic.Unit{{ID: 2, Rank: gamelogic.RankArtillery}}, ToLocation: "africa"},
+			want: pubsub.NackDiscard,
+		},
+		{
+			name:    "publish fails",
+			key:     "army_moves.alice",
+			move:    gamelogic.ArmyMove{Player: gamelogic.Player{Username: "alice"}, Units: []gamelogic.Unit{europe}, ToLocation: "africa"},
+			sendErr: errors.New("closed"),
+			want:    pubsub.NackRequeue,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender := &fakeSender{err: tt.sendErr}
+			ref := newTestReferee(t, sender)
+			r := relay[gamelogic.ArmyMove]{prefix: routing.ArmyMovesPrefix, check: ref.checkMove, done: ref.moveAndDeclare}
+
+			if got := r.handler(sender)(tt.key, tt.move); got != tt.want {
+				t.Fatalf("handler returned %v, want %v", got, tt.want)
+			}
+			wantLoc := gamelogic.Location("europe")
+			if tt.wantKey != "" {
+				wantLoc = tt.move.ToLocation
+			}
+			if got := ref.current().Army("alice").Units[1].Location; got != wantLoc {
+				t.Errorf("unit 1 is in %s on the board, want %s", got, wantLoc)
+			}
+			moves := sender.on("verified.army_moves.")
+			if tt.wantKey == "" {
+				if len(moves) != 0 {
+					t.Fatalf("relayed %d moves, want none", len(moves))
+				}
+				return
+			}
+			if len(moves) != 1 || moves[0].key != tt.wantKey {
+				t.Fatalf("relayed %+v, want one move on %s", moves, tt.wantKey)
+			}
+			if _, ok := moves[0].msg.Headers[routing.SessionTokenHeader]; ok {
+				t.Fatal("relayed message carries a session token")
+			}
+		})
+	}
+}
+
+func TestRelayedMovesCarryTheBoardsArmy(t *testing.T) {
+	sender := &fakeSender{}
+	ref := newTestReferee(t, sender)
+	r := relay[gamelogic.ArmyMove]{prefix: routing.ArmyMovesPrefix, check: ref.checkMove, done: ref.moveAndDeclare}
+
+	// The client claims a second unit and a different rank for the first
+	move := gamelogic.ArmyMove{
+		Player: gamelogic.Player{Username: "alice", Units: map[int]gamelogic.Unit{
+			1: {ID: 1, Rank: gamelogic.RankArtillery, Location: "europe"},
+			2: {ID: 2, Rank: gamelogic.RankArtillery, Location: "asia"},
+		}},
+		Units:      []gamelogic.Unit{{ID: 1, Rank: gamelogic.RankArtillery, Location: "europe"}},
+		ToLocation: "africa",
+	}
+	if got := r.handler(sender)("army_moves.alice", move); got != pubsub.Ack {
+		t.Fatalf("handler returned %v, want %v", got, pubsub.Ack)
+	}
+
+	var relayed gamelogic.ArmyMove
+	if err := json.Unmarshal(sender.on("verified.army_moves.")[0].msg.Body, &relayed); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]gamelogic.Unit{1: {ID: 1, Rank: gamelogic.RankInfantry, Location: "africa", HP: gamelogic.MaxHealth(gamelogic.RankInfantry)}}
+	if !reflect.DeepEqual(relayed.Player.Units, want) {
+		t.Errorf("relayed army %+v, want %+v", relayed.Player.Units, want)
+	}
+}
+
+func TestMovesDeclareWars(t *testing.T) {
+	sender := &fakeSender{}
+	ref := newTestReferee(t, sender)
+	bob := gamelogic.Unit{ID: 1, Rank: gamelogic.RankCavalry, Location: "asia"}
+	if _, err := ref.spawn("bob", bob); err != nil {
+		t.Fatal(err)
+	}
+	r := relay[gamelogic.ArmyMove]{prefix: routing.ArmyMovesPrefix, check: ref.checkMove, done: ref.moveAndDeclare}
+
+	move := gamelogic.ArmyMove{Player: gamelogic.Player{Username: "bob"}, Units: []gamelogic.Unit{bob}, ToLocation: "europe"}
+	if got := r.handler(sender)("army_moves.bob", move); got != pubsub.Ack {
+		t.Fatalf("handler returned %v, want %v", got, pubsub.Ack)
+	}
+	wars := sender.on("verified.war.")
+	if len(wars) != 1 || wars[0].key != "verified.war.bob" {
+		t.Fatalf("declared %+v, want one war on verified.war.bob", wars)
+	}
+	var rw gamelogic.RecognitionOfWar
+	if err := json.Unmarshal(wars[0].msg.Body, &rw); err != nil {
+		t.Fatal(err)
+	}
+	// alice never moved, but the board knows where her unit is
+	if rw.Defender.Username != "alice" || rw.Location != "europe" || len(rw.Defender.Units) != 1 {
+		t.Fatalf("declared %+v, want bob attacking alice in europe", rw)
+	}
+
+	// A second move into the same fight waits for the first war's result
+	if _, err := ref.spawn("bob", gamelogic.Unit{ID: 2, Rank: gamelogic.RankInfantry, Location: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+	move = gamelogic.ArmyMove{Player: gamelogic.Player{Username: "bob"}, Units: []gamelogic.Unit{{ID: 2}}, ToLocation: "europe"}
+	if got := r.handler(sender)("army_moves.bob", move); got != pubsub.Ack {
+		t.Fatalf("handler returned %v, want %v", got, pubsub.Ack)
+	}
+	if len(sender.on("verified.army_moves.")) != 2 || len(sender.on("verified.war.")) != 1 {
+		t.Fatalf("published %+v, want one more move and no war", sender.sent)
+	}
+}
+
+func TestWarResultsAreSettledByTheServer(t *testing.T) {
+	sender := &fakeSender{}
+	ref := newTestReferee(t, sender)
+	if _, err := ref.spawn("bob", gamelogic.Unit{ID: 1, Rank: gamelogic.RankArtillery, Location: "asia"}); err != nil {
+		t.Fatal(err)
+	}
+	checked, err := ref.checkMove("bob", gamelogic.ArmyMove{Player: gamelogic.Player{Username: "bob"}, Units: []gamelogic.Unit{{ID: 1}}, ToLocation: "europe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref.moveAndDeclare(checked)
+	rw, ok := ref.current().PendingWar("bob", "europe")
+	if !ok {
+		t.Fatal("no war was declared")
+	}
+	want, err := gamelogic.ResolveWar(rw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// bob claims to have lost nothing and killed everything
+	claimed := gamelogic.WarReport{Location: "europe", Attacker: "bob", Winners: []string{"alice"}}
+	r := relay[gamelogic.WarReport]{prefix: routing.WarResultsPrefix, check: ref.checkWarResult, done: ref.warResult}
+	sender.sent = nil
+	if got := r.handler(sender)("war_results.bob", claimed); got != pubsub.Ack {
+		t.Fatalf("handler returned %v, want %v", got, pubsub.Ack)
+	}
+	var relayed gamelogic.WarReport
+	if err := json.Unmarshal(sender.on("verified.war_results.")[0].msg.Body, &relayed); err != nil {
+		t.Fatal(err)
+	}
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(relayed)
+	if string(gotJSON) != string(wantJSON) {
+		t.Fatalf("relayed %s, want the server's own result %s", gotJSON, wantJSON)
+	}
+	if _, ok := ref.current().PendingWar("bob", "europe"); ok {
+		t.Fatal("the war is still pending after its result")
+	}
+
+	// The same result again has no war to settle
+	if got := r.handler(sender)("war_results.bob", claimed); got != pubsub.NackDiscard {
+		t.Fatalf("repeated result returned %v, want %v", got, pubsub.NackDiscard)
+	}
+}
+
+func TestCheckWarResultSender(t *testing.T) {
+	ref := newTestReferee(t, &fakeSender{})
+	if _, err := ref.checkWarResult("bob", gamelogic.WarReport{Attacker: "alice", Location: "europe"}); err == nil {
+		t.Error("accepted a result sent by someone other than its attacker")
+	}
+	if _, err := ref.checkWarResult("alice", gamelogic.WarReport{Attacker: "alice", Location: "europe"}); err == nil {
+		t.Error("accepted a result for a war that was never declared")
+	}
+}
+
+func TestMovesAnnounceControl(t *testing.T) {
+	sender := &fakeSender{}
+	ref := newTestReferee(t, sender)
+	sender.sent = nil
+	r := relay[gamelogic.ArmyMove]{prefix: routing.ArmyMovesPrefix, check: ref.checkMove, done: ref.moveAndDeclare}
+
+	move := gamelogic.ArmyMove{Player: gamelogic.Player{Username: "alice"}, Units: []gamelogic.Unit{{ID: 1}}, ToLocation: "africa"}
+	if got := r.handler(sender)("army_moves.alice", move); got != pubsub.Ack {
+		t.Fatalf("handler returned %v, want %v", got, pubsub.Ack)
+	}
+	announced := sender.on("verified.territory.")
+	if len(announced) != 1 || announced[0].key != "verified.territory.alice" {
+		t.Fatalf("announced %+v, want one change on verified.territory.alice", announced)
+	}
+	var change gamelogic.ControlChange
+	if err := json.Unmarshal(announced[0].msg.Body, &change); err != nil {
+		t.Fatal(err)
+	}
+	want := gamelogic.ControlChange{Location: "africa", Owner: "alice", Reason: gamelogic.ControlOccupied}
+	if change != want {
+		t.Fatalf("announced %+v, want %+v", change, want)
+	}
+}
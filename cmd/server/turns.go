@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/auth"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// turnEngine runs the turn clock. It opens a turn, collects orders until
+// the turn is up, then closes it and broadcasts every order at once.
+type turnEngine struct {
+	mu        *sync.Mutex
+	mode      string
+	length    time.Duration
+	sessions  *auth.Sessions
+	publisher pubsub.Sender
+	ref       *referee
+	state     gamelogic.TurnState
+	orders    []gamelogic.ArmyMove
+	// changes are the control changes the turn's orders made on the
+	// board, kept quiet until the turn ends
+	changes []gamelogic.ControlChange
+	// onEnd, if set, is given every turn's moves after they are announced
+	onEnd func([]gamelogic.ArmyMove)
+}
+
+func newTurnEngine(mode string, length time.Duration, sessions *auth.Sessions, publisher pubsub.Sender, ref *referee) *turnEngine {
+	return &turnEngine{
+		mu:        &sync.Mutex{},
+		mode:      mode,
+		length:    length,
+		sessions:  sessions,
+		publisher: publisher,
+		ref:       ref,
+	}
+}
+
+// run plays turns one after another, forever
+func (t *turnEngine) run() {
+	for {
+		if !t.start() {
+			// Nobody to give the turn to yet
+			time.Sleep(t.length)
+			continue
+		}
+		time.Sleep(t.length)
+		moves := t.end()
+		if t.onEnd != nil {
+			t.onEnd(moves)
+		}
+	}
+}
+
+// start opens the next turn and announces it. In sequential mode it is
+// false when no player is logged in.
+func (t *turnEngine) start() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	player := ""
+	if t.mode == gamelogic.TurnsSequential {
+		player = t.nextPlayer()
+		if player == "" {
+			return false
+		}
+	}
+	t.state = gamelogic.TurnState{
+		Number: t.state.Number + 1,
+		Open:   true,
+		Player: player,
+		EndsAt: time.Now().Add(t.length),
+	}
+	t.orders = nil
+	t.changes = nil
+	t.announce(t.state)
+	return true
+}
+
+// nextPlayer picks whoever comes after the last player, by name, among
+// those logged in
+func (t *turnEngine) nextPlayer() string {
+	active := t.sessions.Active()
+	if len(active) == 0 {
+		return ""
+	}
+	sort.Strings(active)
+	for _, name := range active {
+		if name > t.state.Player {
+			return name
+		}
+	}
+	return active[0]
+}
+
+// end closes the turn and broadcasts its orders. Each move is sent with
+// its player's army as the board has it now, so everyone sees where each
+// army ended up.
+func (t *turnEngine) end() []gamelogic.ArmyMove {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	board := t.ref.current()
+	moves := []gamelogic.ArmyMove{}
+	for _, move := range t.orders {
+		move.Player = board.Army(move.Player.Username)
+		moves = append(moves, move)
+	}
+
+	t.state.Open = false
+	ended := t.state
+	ended.Moves = moves
+	t.announce(ended)
+	t.ref.announce(t.changes)
+
+	// Wars are declared once everyone has the turn's moves. Where several
+	// players moved into the same fight, the first by name attacks.
+	movers := []string{}
+	for _, move := range moves {
+		if !slices.Contains(movers, move.Player.Username) {
+			movers = append(movers, move.Player.Username)
+		}
+	}
+	slices.Sort(movers)
+	for _, name := range movers {
+		t.ref.declare(name)
+	}
+	return moves
+}
+
+func (t *turnEngine) announce(ts gamelogic.TurnState) {
+	defer fmt.Print("> ")
+	if err := pubsub.PublishJSON(t.publisher, routing.ExchangePerilDirect, routing.TurnKey, ts); err != nil {
+		fmt.Printf("\nFailed to announce turn %d: %v\n", ts.Number, err)
+		return
+	}
+	switch {
+	case ts.Open && ts.Player != "":
+		fmt.Printf("\nTurn %d started for %s\n", ts.Number, ts.Player)
+	case ts.Open:
+		fmt.Printf("\nTurn %d started\n", ts.Number)
+	default:
+		fmt.Printf("\nTurn %d ended with %d order(s)\n", ts.Number, len(ts.Moves))
+	}
+}
+
+// order accepts a move for the current turn, or says why not. The move is
+// made on the board at once, so a player's later orders start from where
+// its earlier ones left its units.
+func (t *turnEngine) order(o gamelogic.TurnOrder) error {
+	if !t.sessions.Validate(o.Username, o.Token) {
+		return fmt.Errorf("no valid session for %s", o.Username)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.state.Open || o.Turn != t.state.Number {
+		return fmt.Errorf("too late, turn %d is over", o.Turn)
+	}
+	if t.state.Player != "" && t.state.Player != o.Username {
+		return fmt.Errorf("it is %s's turn", t.state.Player)
+	}
+	move, err := t.ref.checkMove(o.Username, o.Move)
+	if err != nil {
+		return err
+	}
+	t.changes = append(t.changes, t.ref.move(move)...)
+	t.orders = append(t.orders, move)
+	return nil
+}
+
+// refuseMove is the moves relay's check with turns on. Moves only count
+// as turn orders then, so one published straight on army_moves cannot get
+// past the turn clock.
+func refuseMove(username string, move gamelogic.ArmyMove) (gamelogic.ArmyMove, error) {
+	return move, errors.New("turns are on, moves are only taken as turn orders")
+}
+
+// handlerTurnOrder answers players giving orders
+func handlerTurnOrder(t *turnEngine) func(gamelogic.TurnOrder) gamelogic.TurnOrderResponse {
+	return func(o gamelogic.TurnOrder) gamelogic.TurnOrderResponse {
+		if err := t.order(o); err != nil {
+			defer fmt.Print("> ")
+			fmt.Printf("\nRejected order from %s: %v\n", o.Username, err)
+			return gamelogic.TurnOrderResponse{Error: err.Error()}
+		}
+		return gamelogic.TurnOrderResponse{}
+	}
+}
+
+// commandTurn shows the turn in play
+func commandTurn(t *turnEngine) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state.Number == 0 {
+		fmt.Println("No turn has started yet")
+		return
+	}
+	status := "closed"
+	if t.state.Open {
+		status = fmt.Sprintf("open until %s", t.state.EndsAt.Format(time.TimeOnly))
+	}
+	fmt.Printf("Turn %d (%s) is %s with %d order(s)\n", t.state.Number, t.mode, status, len(t.orders))
+	if t.state.Player != "" {
+		fmt.Printf("It is %s's turn\n", t.state.Player)
+	}
+	movers := []string{}
+	for _, move := range t.orders {
+		if !slices.Contains(movers, move.Player.Username) {
+			movers = append(movers, move.Player.Username)
+		}
+	}
+	for _, name := range movers {
+		fmt.Printf("* %s\n", name)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/auth"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestTurnOrders(t *testing.T) {
+	sender := &fakeSender{}
+	ref := newTestReferee(t, sender)
+	sessions := auth.NewSessions(time.Hour)
+	token, _, _, err := sessions.Start("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	turns := newTurnEngine(gamelogic.TurnsSimultaneous, time.Minute, sessions, sender, ref)
+	order := func(turn int, to gamelogic.Location) gamelogic.TurnOrder {
+		move := gamelogic.ArmyMove{Player: gamelogic.Player{Username: "alice"}, Units: []gamelogic.Unit{{ID: 1}}, ToLocation: to}
+		return gamelogic.TurnOrder{Username: "alice", Token: token, Turn: turn, Move: move}
+	}
+
+	if !turns.start() {
+		t.Fatal("the turn did not start")
+	}
+	if err := turns.order(order(1, "africa")); err != nil {
+		t.Fatalf("order in time: %v", err)
+	}
+	if err := turns.order(order(0, "europe")); err == nil {
+		t.Error("accepted an order for an earlier turn")
+	}
+	bad := order(1, "europe")
+	bad.Token = "stolen"
+	if err := turns.order(bad); err == nil {
+		t.Error("accepted an order without a session")
+	}
+	sender.sent = nil
+
+	moves := turns.end()
+	if len(moves) != 1 || moves[0].Player.Units[1].Location != "africa" {
+		t.Fatalf("turn ended with %+v, want alice's unit in africa", moves)
+	}
+	// The control change the order made is only announced with the turn
+	announced := sender.on("verified.territory.")
+	if len(announced) != 1 {
+		t.Fatalf("announced %d control changes at the end of the turn, want 1", len(announced))
+	}
+	var change gamelogic.ControlChange
+	if err := json.Unmarshal(announced[0].msg.Body, &change); err != nil || change.Location != "africa" {
+		t.Fatalf("announced %+v (%v), want africa", change, err)
+	}
+
+	if err := turns.order(order(1, "europe")); err == nil {
+		t.Error("accepted an order after the turn was over")
+	}
+	if loc := ref.current().Army("alice").Units[1].Location; loc != "africa" {
+		t.Errorf("late order moved the unit to %s", loc)
+	}
+}
+
+func TestSequentialTurnOrders(t *testing.T) {
+	sender := &fakeSender{}
+	ref := newTestReferee(t, sender)
+	sessions := auth.NewSessions(time.Hour)
+	if _, _, _, err := sessions.Start("alice"); err != nil {
+		t.Fatal(err)
+	}
+	token, _, _, err := sessions.Start("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	turns := newTurnEngine(gamelogic.TurnsSequential, time.Minute, sessions, sender, ref)
+	if !turns.start() {
+		t.Fatal("the turn did not start")
+	}
+
+	move := gamelogic.ArmyMove{Player: gamelogic.Player{Username: "bob"}, Units: []gamelogic.Unit{{ID: 1}}, ToLocation: "africa"}
+	if err := turns.order(gamelogic.TurnOrder{Username: "bob", Token: token, Turn: 1, Move: move}); err == nil {
+		t.Error("accepted bob's order on alice's turn")
+	}
+}
+
+func TestMovesRefusedWithTurns(t *testing.T) {
+	sender := &fakeSender{}
+	ref := newTestReferee(t, sender)
+	moves := relay[gamelogic.ArmyMove]{prefix: routing.ArmyMovesPrefix, check: refuseMove, done: ref.moveAndDeclare}
+	handler := moves.handler(sender)
+	sender.sent = nil
+
+	move := gamelogic.ArmyMove{Player: gamelogic.Player{Username: "alice"}, Units: []gamelogic.Unit{{ID: 1}}, ToLocation: "africa"}
+	if ack := handler("army_moves.alice", move); ack != pubsub.NackDiscard {
+		t.Fatalf("direct move got %v, want it discarded", ack)
+	}
+	if relayed := sender.on("verified."); len(relayed) != 0 {
+		t.Fatalf("relayed %d messages", len(relayed))
+	}
+	if loc := ref.current().Army("alice").Units[1].Location; loc != "europe" {
+		t.Errorf("direct move took the unit to %s", loc)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: tlsproxy [flags]")
+	fmt.Fprintln(os.Stderr, "Terminates TLS in front of a plain RabbitMQ listener, so amqps:// and")
+	fmt.Fprintln(os.Stderr, "mutual TLS can be tried locally.")
+	fmt.Fprintln(os.Stderr, "Example: tlsproxy -cert certs/server.pem -key certs/server.key -client-ca certs/ca.pem")
+	fmt.Fprintln(os.Stderr, "Flags:")
+	flag.PrintDefaults()
+}
+
+func main() {
+	listen := flag.String("listen", "localhost:5671", "address to accept TLS connections on")
+	upstream := flag.String("upstream", "localhost:5672", "plain AMQP address to forward to")
+	certFile := flag.String("cert", "", "server certificate")
+	keyFile := flag.String("key", "", "server key")
+	clientCA := flag.String("client-ca", "", "require client certificates signed by this CA bundle")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *certFile == "" || *keyFile == "" {
+		usage()
+		os.Exit(2)
+	}
+
+	cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+	if err != nil {
+		log.Fatalf("Failed to load server certificate: %s\n", err)
+	}
+	tlsConfig := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if *clientCA != "" {
+		pem, err := os.ReadFile(*clientCA)
+		if err != nil {
+			log.Fatalf("Failed to read client CA: %s\n", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Fatalf("No certificates found in %s\n", *clientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	ln, err := tls.Listen("tcp", *listen, tlsConfig)
+	if err != nil {
+		log.Fatalf("Failed to listen: %s\n", err)
+	}
+	log.Printf("Forwarding TLS on %s to %s\n", *listen, *upstream)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			log.Printf("Failed to accept: %s\n", err)
+			continue
+		}
+		go forward(conn.(*tls.Conn), *upstream)
+	}
+}
+
+// forward completes the handshake, so a rejected client certificate is
+// logged here, then copies bytes both ways until either side closes
+func forward(conn *tls.Conn, upstream string) {
+	defer conn.Close()
+
+	if err := conn.Handshake(); err != nil {
+		log.Printf("Handshake with %s failed: %s\n", conn.RemoteAddr(), err)
+		return
+	}
+	peer := "no client certificate"
+	if certs := conn.ConnectionState().PeerCertificates; len(certs) > 0 {
+		peer = certs[0].Subject.CommonName
+	}
+	log.Printf("Accepted %s (%s)\n", conn.RemoteAddr(), peer)
+
+	up, err := net.Dial("tcp", upstream)
+	if err != nil {
+		log.Printf("Failed to reach %s: %s\n", upstream, err)
+		return
+	}
+	defer up.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(up, conn)
+		up.(*net.TCPConn).CloseWrite()
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(conn, up)
+		conn.CloseWrite()
+	}()
+	wg.Wait()
+}
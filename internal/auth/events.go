@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// SessionEventMaxAge is how far a session event's issue time may be from
+// now, either way, before it is refused. It stops a recorded event from
+// being replayed later.
+const SessionEventMaxAge = time.Minute
+
+var (
+	ErrBadSignature = errors.New("bad session event signature")
+	ErrStaleEvent   = errors.New("stale session event")
+)
+
+// EventSigner signs and checks the session events server instances share.
+// Every instance must be given the same secret.
+type EventSigner struct {
+	key []byte
+}
+
+// NewEventSigner signs with secret. An empty secret picks a random one,
+// which no other instance can know, so sessions are then not shared.
+func NewEventSigner(secret string) (*EventSigner, error) {
+	if secret != "" {
+		return &EventSigner{key: []byte(secret)}, nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("could not generate session secret: %w", err)
+	}
+	return &EventSigner{key: key}, nil
+}
+
+// Sign stamps ev with the current time and signs it
+func (s *EventSigner) Sign(ev routing.SessionEvent) routing.SessionEvent {
+	ev.IssuedAt = time.Now()
+	ev.Signature = s.signature(ev)
+	return ev
+}
+
+// Verify checks that ev was signed with this secret and is recent
+func (s *EventSigner) Verify(ev routing.SessionEvent) error {
+	if !hmac.Equal([]byte(ev.Signature), []byte(s.signature(ev))) {
+		return ErrBadSignature
+	}
+	if age := time.Since(ev.IssuedAt); age > SessionEventMaxAge || age < -SessionEventMaxAge {
+		return fmt.Errorf("%w: issued %s", ErrStaleEvent, ev.IssuedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (s *EventSigner) signature(ev routing.SessionEvent) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "%s\n%s\n%d\n%t\n%d", ev.Username, ev.TokenHash, ev.ExpiresAt.UnixNano(), ev.Active, ev.IssuedAt.UnixNano())
+	return hex.EncodeToString(mac.Sum(nil))
+}
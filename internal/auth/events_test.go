@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func TestEventSignerVerify(t *testing.T) {
+	signer, err := NewEventSigner("shared secret")
+	if err != nil {
+		t.Fatalf("NewEventSigner: %v", err)
+	}
+	sameSecret, _ := NewEventSigner("shared secret")
+	otherSecret, _ := NewEventSigner("other secret")
+	random, _ := NewEventSigner("")
+
+	login := routing.SessionEvent{Username: "alice", TokenHash: HashToken("t"), ExpiresAt: time.Now().Add(time.Hour), Active: true}
+
+	tests := []struct {
+		name     string
+		verifier *EventSigner
+		event    func() routing.SessionEvent
+		wantErr  error
+	}{
+		{"signed", sameSecret, func() routing.SessionEvent { return signer.Sign(login) }, nil},
+		{"signed, sent as JSON", sameSecret, func() routing.SessionEvent {
+			data, err := json.Marshal(signer.Sign(login))
+			if err != nil {
+				t.Fatalf("Marshal: %v", err)
+			}
+			var ev routing.SessionEvent
+			if err := json.Unmarshal(data, &ev); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			return ev
+		}, nil},
+		{"unsigned", sameSecret, func() routing.SessionEvent { return login }, ErrBadSignature},
+		{"other secret", otherSecret, func() routing.SessionEvent { return signer.Sign(login) }, ErrBadSignature},
+		{"no secret", random, func() routing.SessionEvent { return signer.Sign(login) }, ErrBadSignature},
+		{"hash swapped", sameSecret, func() routing.SessionEvent {
+			ev := signer.Sign(login)
+			ev.TokenHash = HashToken("mine")
+			return ev
+		}, ErrBadSignature},
+		{"login turned into logout", sameSecret, func() routing.SessionEvent {
+			ev := signer.Sign(login)
+			ev.Active = false
+			return ev
+		}, ErrBadSignature},
+		{"user swapped", sameSecret, func() routing.SessionEvent {
+			ev := signer.Sign(login)
+			ev.Username = "bob"
+			return ev
+		}, ErrBadSignature},
+		{"replayed", sameSecret, func() routing.SessionEvent {
+			ev := login
+			ev.IssuedAt = time.Now().Add(-2 * SessionEventMaxAge)
+			ev.Signature = signer.signature(ev)
+			return ev
+		}, ErrStaleEvent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.verifier.Verify(tt.event())
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Verify error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
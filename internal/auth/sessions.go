@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const DefaultSessionTTL = 12 * time.Hour
+
+var ErrAlreadyLoggedIn = errors.New("user already has an active session")
+
+type session struct {
+	tokenHash string
+	expires   time.Time
+}
+
+// Sessions tracks one active session per username. Only token hashes are
+// kept so they can be shared with other server instances without leaking
+// usable tokens.
+type Sessions struct {
+	ttl    time.Duration
+	mu     *sync.Mutex
+	byUser map[string]session
+}
+
+func NewSessions(ttl time.Duration) *Sessions {
+	return &Sessions{
+		ttl:    ttl,
+		mu:     &sync.Mutex{},
+		byUser: map[string]session{},
+	}
+}
+
+// Start issues a new token for username unless it already has a live
+// session. A client that crashed without logging out can log in again
+// once its session expires or the server kicks it.
+func (s *Sessions) Start(username string) (token, tokenHash string, expires time.Time, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", time.Time{}, fmt.Errorf("could not generate session token: %w", err)
+	}
+	token = hex.EncodeToString(b)
+	tokenHash = HashToken(token)
+	expires = time.Now().Add(s.ttl)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.byUser[username]; ok && time.Now().Before(current.expires) {
+		return "", "", time.Time{}, ErrAlreadyLoggedIn
+	}
+	s.byUser[username] = session{tokenHash: tokenHash, expires: expires}
+	return token, tokenHash, expires, nil
+}
+
+// Adopt records a session issued elsewhere
+func (s *Sessions) Adopt(username, tokenHash string, expires time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byUser[username] = session{tokenHash: tokenHash, expires: expires}
+}
+
+// End removes the session for username if tokenHash matches it
+func (s *Sessions) End(username, tokenHash string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.byUser[username]
+	if !ok || !equalHashes(current.tokenHash, tokenHash) {
+		return false
+	}
+	delete(s.byUser, username)
+	return true
+}
+
+// Kick removes the session for username regardless of token
+func (s *Sessions) Kick(username string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.byUser[username]
+	delete(s.byUser, username)
+	return ok
+}
+
+// Validate reports whether token belongs to the live session for username
+func (s *Sessions) Validate(username, token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.byUser[username]
+	if !ok || time.Now().After(current.expires) {
+		return false
+	}
+	return equalHashes(current.tokenHash, HashToken(token))
+}
+
+// Active returns the usernames with live sessions
+func (s *Sessions) Active() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usernames := []string{}
+	for username, current := range s.byUser {
+		if time.Now().Before(current.expires) {
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func equalHashes(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
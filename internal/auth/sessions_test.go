@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSessionsValidate(t *testing.T) {
+	s := NewSessions(time.Hour)
+	token, _, _, err := s.Start("alice")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		token    string
+		want     bool
+	}{
+		{"own token", "alice", token, true},
+		{"wrong token", "alice", "not-the-token", false},
+		{"empty token", "alice", "", false},
+		{"token of another user", "bob", token, false},
+		{"token hash instead of token", "alice", HashToken(token), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.Validate(tt.username, tt.token); got != tt.want {
+				t.Fatalf("Validate(%q) = %v, want %v", tt.username, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionsStartRefusesLiveSession(t *testing.T) {
+	s := NewSessions(time.Hour)
+	token, _, _, err := s.Start("alice")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, _, _, err := s.Start("alice"); !errors.Is(err, ErrAlreadyLoggedIn) {
+		t.Fatalf("second Start: %v, want ErrAlreadyLoggedIn", err)
+	}
+	if !s.Validate("alice", token) {
+		t.Fatal("the live session was replaced")
+	}
+
+	// A kicked player can log straight back in
+	s.Kick("alice")
+	if _, _, _, err := s.Start("alice"); err != nil {
+		t.Fatalf("Start after a kick: %v", err)
+	}
+
+	// So can one whose session expired
+	expired := NewSessions(-time.Second)
+	if _, _, _, err := expired.Start("alice"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, _, _, err := expired.Start("alice"); err != nil {
+		t.Fatalf("Start after expiry: %v", err)
+	}
+}
+
+func TestSessionsExpire(t *testing.T) {
+	s := NewSessions(-time.Second)
+	token, _, _, err := s.Start("alice")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if s.Validate("alice", token) {
+		t.Fatal("an expired session is valid")
+	}
+	if active := s.Active(); len(active) != 0 {
+		t.Fatalf("Active() = %v, want none", active)
+	}
+}
+
+func TestSessionsEnd(t *testing.T) {
+	tests := []struct {
+		name      string
+		end       func(s *Sessions, hash string) bool
+		wantEnded bool
+	}{
+		{"matching hash", func(s *Sessions, hash string) bool { return s.End("alice", hash) }, true},
+		{"wrong hash", func(s *Sessions, hash string) bool { return s.End("alice", HashToken("other")) }, false},
+		{"other user", func(s *Sessions, hash string) bool { return s.End("bob", hash) }, false},
+		{"kick", func(s *Sessions, hash string) bool { return s.Kick("alice") }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewSessions(time.Hour)
+			token, hash, _, err := s.Start("alice")
+			if err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			if got := tt.end(s, hash); got != tt.wantEnded {
+				t.Fatalf("ended = %v, want %v", got, tt.wantEnded)
+			}
+			if s.Validate("alice", token) == tt.wantEnded {
+				t.Fatalf("Validate after ending = %v", !tt.wantEnded)
+			}
+		})
+	}
+}
+
+func TestSessionsAdopt(t *testing.T) {
+	other := NewSessions(time.Hour)
+	token, hash, expires, err := other.Start("alice")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	s := NewSessions(time.Hour)
+	s.Adopt("alice", hash, expires)
+	if !s.Validate("alice", token) {
+		t.Fatal("an adopted session does not validate its token")
+	}
+	if active := s.Active(); !slices.Equal(active, []string{"alice"}) {
+		t.Fatalf("Active() = %v, want [alice]", active)
+	}
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// dummyHash is compared against when a user does not exist so unknown and
+// known usernames take the same time to reject
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("peril"), bcrypt.DefaultCost)
+
+// lockTimeout is how long SetPassword waits for another process writing
+// the user file, after which its lock is taken to be stale
+const lockTimeout = 5 * time.Second
+
+// UserStore is a file of "username:bcrypt-hash" lines. Server instances
+// share the file, so a store reloads it whenever another has written it
+// since.
+type UserStore struct {
+	path    string
+	mu      *sync.RWMutex
+	hashes  map[string][]byte
+	modTime time.Time
+}
+
+// LoadUserStore reads the user file at path. A missing file is an empty store.
+func LoadUserStore(path string) (*UserStore, error) {
+	store := &UserStore{
+		path:   path,
+		mu:     &sync.RWMutex{},
+		hashes: map[string][]byte{},
+	}
+	if err := store.load(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// load reads the file into the store. The caller holds the write lock, or
+// is the only one with the store.
+func (s *UserStore) load() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not open user store: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("could not open user store: %w", err)
+	}
+
+	hashes := map[string][]byte{}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected username:hash", s.path, lineNo)
+		}
+		hashes[username] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read user store: %w", err)
+	}
+	s.hashes, s.modTime = hashes, info.ModTime()
+	return nil
+}
+
+// reload reads the file again if it has changed since it was last read
+// or written. A file that no longer reads is reported and the users
+// already loaded are kept.
+func (s *UserStore) reload() {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return
+	}
+	s.mu.RLock()
+	current := info.ModTime().Equal(s.modTime)
+	s.mu.RUnlock()
+	if current {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		fmt.Printf("Failed to reload users: %v\n", err)
+	}
+}
+
+// Verify checks password against the stored hash for username
+func (s *UserStore) Verify(username, password string) error {
+	s.reload()
+	s.mu.RLock()
+	hash, ok := s.hashes[username]
+	s.mu.RUnlock()
+
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// SetPassword adds username or replaces its password and saves the store
+func (s *UserStore) SetPassword(username, password string) error {
+	return s.SetPasswords(map[string]string{username: password})
+}
+
+// SetPasswords adds or updates many users in one write. Users given the
+// same password share its hash, so adding thousands of test accounts takes
+// one bcrypt run. It holds a lock file while it rereads and rewrites the
+// file, so users added by another process at the same time are kept.
+func (s *UserStore) SetPasswords(passwords map[string]string) error {
+	hashes := map[string][]byte{}
+	byPassword := map[string][]byte{}
+	for username, password := range passwords {
+		if err := ValidateUsername(username); err != nil {
+			return err
+		}
+		if password == "" {
+			return errors.New("password must not be empty")
+		}
+		hash, ok := byPassword[password]
+		if !ok {
+			var err error
+			hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			if err != nil {
+				return fmt.Errorf("could not hash password: %w", err)
+			}
+			byPassword[password] = hash
+		}
+		hashes[username] = hash
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	for username, hash := range hashes {
+		s.hashes[username] = hash
+	}
+	return s.save()
+}
+
+// lockFile creates path, waiting while another process holds it. A lock
+// older than lockTimeout was left by a process that died, and is taken.
+func lockFile(path string) (unlock func(), err error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("could not lock user store: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > lockTimeout {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("could not lock user store: another process is writing it")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// save rewrites the whole file through a temporary file so a crash never
+// leaves a half written store behind
+func (s *UserStore) save() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".users-*")
+	if err != nil {
+		return fmt.Errorf("could not write user store: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for username, hash := range s.hashes {
+		fmt.Fprintf(w, "%s:%s\n", username, hash)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write user store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write user store: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("could not write user store: %w", err)
+	}
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// ValidateUsername rejects names that would break routing keys or the
+// user file format
+func ValidateUsername(username string) error {
+	if username == "" {
+		return errors.New("username must not be empty")
+	}
+	if strings.ContainsAny(username, ":.*# \t") {
+		return fmt.Errorf("username %q contains invalid characters", username)
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestUserStoreShared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.txt")
+	a, err := LoadUserStore(path)
+	if err != nil {
+		t.Fatalf("LoadUserStore: %v", err)
+	}
+	b, err := LoadUserStore(path)
+	if err != nil {
+		t.Fatalf("LoadUserStore: %v", err)
+	}
+
+	// A user added through one instance can log in through the other
+	if err := a.SetPassword("alice", "s3cret"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	if err := b.Verify("alice", "s3cret"); err != nil {
+		t.Fatalf("the other store does not know alice: %v", err)
+	}
+
+	// Neither instance's write loses the other's
+	if err := b.SetPassword("bob", "hunter2"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	if err := a.SetPassword("carol", "pa55"); err != nil {
+		t.Fatalf("SetPassword: %v", err)
+	}
+	fresh, err := LoadUserStore(path)
+	if err != nil {
+		t.Fatalf("LoadUserStore: %v", err)
+	}
+	for username, password := range map[string]string{"alice": "s3cret", "bob": "hunter2", "carol": "pa55"} {
+		if err := fresh.Verify(username, password); err != nil {
+			t.Errorf("%s was lost: %v", username, err)
+		}
+	}
+	if err := fresh.Verify("alice", "wrong"); err != ErrInvalidCredentials {
+		t.Errorf("wrong password gave %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/player"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// View is what a strategy knows when it picks its next command
+type View struct {
+	Map    *gamelogic.Map
+	Player gamelogic.Player
+	// Enemies holds each opponent's army as of their last move broadcast
+	Enemies map[string]gamelogic.Player
+	// Held lists the territories the player controls
+	Held []gamelogic.Location
+}
+
+// EnemyUnits counts known enemy units by location
+func (v View) EnemyUnits() map[gamelogic.Location]int {
+	counts := map[gamelogic.Location]int{}
+	for _, enemy := range v.Enemies {
+		for _, unit := range enemy.Units {
+			counts[unit.Location]++
+		}
+	}
+	return counts
+}
+
+// Bot plays one player without a human at the prompt. Its moves go through
+// the same GameState and handlers as cmd/client.
+type Bot struct {
+	gs        *gamelogic.GameState
+	publisher pubsub.Sender
+	codec     pubsub.Codec
+	strategy  Strategy
+	rng       *rand.Rand
+	// Order, if set, hands moves to the turn clock instead of publishing
+	// them straight away
+	Order func(gamelogic.ArmyMove) error
+
+	mu      *sync.Mutex
+	enemies map[string]gamelogic.Player
+}
+
+// New plays gs with strategy. Moves are published with the session token,
+// so the server relays them to the other players.
+func New(gs *gamelogic.GameState, publisher pubsub.Sender, token string, strategy Strategy, seed int64) *Bot {
+	return &Bot{
+		gs:        gs,
+		publisher: publisher,
+		codec:     player.SessionCodec(token),
+		strategy:  strategy,
+		rng:       rand.New(rand.NewSource(seed)),
+		mu:        &sync.Mutex{},
+		enemies:   map[string]gamelogic.Player{},
+	}
+}
+
+// Observe records an opponent's army from a move broadcast. Pass it as
+// player.Options.OnMove.
+func (b *Bot) Observe(move gamelogic.ArmyMove) {
+	if move.Player.Username == b.gs.GetUsername() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.enemies[move.Player.Username] = move.Player
+}
+
+func (b *Bot) View() View {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	enemies := make(map[string]gamelogic.Player, len(b.enemies))
+	for name, p := range b.enemies {
+		enemies[name] = p
+	}
+	return View{Map: b.gs.Map(), Player: b.gs.GetPlayerSnap(), Enemies: enemies, Held: b.gs.Held()}
+}
+
+// Step asks the strategy for one command and carries it out. It does
+// nothing while the game is paused or, with turns on, while it may not
+// give orders.
+func (b *Bot) Step() error {
+	if b.gs.IsPaused() || b.gs.CanOrder() != nil {
+		return nil
+	}
+
+	words := b.strategy.Next(b.View(), b.rng)
+	if len(words) == 0 {
+		return nil
+	}
+
+	switch words[0] {
+	case "spawn":
+		_, err := b.gs.CommandSpawn(words)
+		return err
+	case "move":
+		before := b.gs.GetPlayerSnap()
+		move, err := b.gs.CommandMove(words)
+		if err != nil {
+			return err
+		}
+		if b.Order != nil {
+			if err := b.Order(move); err != nil {
+				b.gs.RollbackMove(move, gamelogic.UnitsBefore(before, move))
+				return fmt.Errorf("failed to give order: %w", err)
+			}
+			return nil
+		}
+		err = pubsub.Publish(b.publisher, routing.ExchangePerilTopic, player.MoveKey(b.gs.GetUsername()), b.codec, move)
+		if err != nil {
+			return fmt.Errorf("failed to publish move: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("strategy %s returned unknown command %q", b.strategy.Name(), words[0])
+	}
+}
+
+// Run steps every interval until ctx is done
+func (b *Bot) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.Step(); err != nil {
+				log.Printf("%s: %s\n", b.gs.GetUsername(), err)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type fakeSender struct {
+	sent []amqp.Publishing
+}
+
+func (f *fakeSender) PublishWithContext(_ context.Context, _, _ string, _, _ bool, msg amqp.Publishing) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// script plays the commands it is given, one per step
+type script [][]string
+
+func (s *script) Name() string { return "script" }
+
+func (s *script) Next(View, *rand.Rand) []string {
+	if len(*s) == 0 {
+		return nil
+	}
+	next := (*s)[0]
+	*s = (*s)[1:]
+	return next
+}
+
+func TestMovesCarrySession(t *testing.T) {
+	gs := gamelogic.NewGameState("bot1")
+	sender := &fakeSender{}
+	strategy := &script{{"spawn", "europe", "infantry"}, {"move", "africa", "1"}}
+	b := New(gs, sender, "token-1", strategy, 1)
+
+	for i := 0; i < 2; i++ {
+		if err := b.Step(); err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+	}
+	if len(sender.sent) != 1 {
+		t.Fatalf("published %d messages, want the move", len(sender.sent))
+	}
+	// The server drops moves without the session token
+	if token := sender.sent[0].Headers[routing.SessionTokenHeader]; token != "token-1" {
+		t.Fatalf("move published with session %v, want token-1", token)
+	}
+}
@@ -0,0 +1,220 @@
+package bot
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+)
+
+// DefaultMaxUnits caps how many units the built-in strategies spawn
+const DefaultMaxUnits = 10
+
+// Strategy picks a bot's next command as the words a player would type at
+// the client prompt, such as ["spawn", "europe", "infantry"]. It returns
+// nil to do nothing this turn.
+type Strategy interface {
+	Name() string
+	Next(v View, rng *rand.Rand) []string
+}
+
+// ByName returns a fresh built-in strategy
+func ByName(name string) (Strategy, error) {
+	switch name {
+	case "random":
+		return &Random{MaxUnits: DefaultMaxUnits}, nil
+	case "aggressive":
+		return &Aggressive{MaxUnits: DefaultMaxUnits, ArmySize: 3}, nil
+	case "defensive":
+		return &Defensive{MaxUnits: DefaultMaxUnits}, nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q, use random, aggressive or defensive", name)
+}
+
+// Random spawns and moves at random
+type Random struct {
+	MaxUnits int
+}
+
+func (s *Random) Name() string { return "random" }
+
+func (s *Random) Next(v View, rng *rand.Rand) []string {
+	units := sortedUnits(v.Player)
+	if len(units) == 0 || (len(units) < s.MaxUnits && rng.Intn(2) == 0) {
+		spots := v.spawnSpots()
+		ranks := gamelogic.Ranks()
+		return spawn(spots[rng.Intn(len(spots))], ranks[rng.Intn(len(ranks))])
+	}
+	unit := units[rng.Intn(len(units))]
+	options := v.Map.Reachable(unit.Location, gamelogic.MoveRange(unit.Rank))
+	if len(options) == 0 {
+		return nil
+	}
+	return move(options[rng.Intn(len(options))], []gamelogic.Unit{unit})
+}
+
+// Aggressive builds an army of artillery, then marches all of it on the
+// weakest known enemy position
+type Aggressive struct {
+	MaxUnits int
+	// ArmySize is how many units it gathers before attacking
+	ArmySize int
+}
+
+func (s *Aggressive) Name() string { return "aggressive" }
+
+func (s *Aggressive) Next(v View, rng *rand.Rand) []string {
+	spots := v.spawnSpots()
+	units := sortedUnits(v.Player)
+	if len(units) < s.ArmySize {
+		return spawn(spots[rng.Intn(len(spots))], gamelogic.RankArtillery)
+	}
+
+	target, ok := weakest(v.EnemyUnits(), v.Map.Locations())
+	if ok {
+		if words := toward(v.Map, units, target); words != nil {
+			return words
+		}
+	}
+	// Reinforcements spawn anywhere and join the attack on later turns
+	if len(units) < s.MaxUnits {
+		return spawn(spots[rng.Intn(len(spots))], gamelogic.RankArtillery)
+	}
+	return nil
+}
+
+// Defensive keeps its army together at home, away from known enemies, and
+// moves home when enemies get there
+type Defensive struct {
+	MaxUnits int
+	home     gamelogic.Location
+}
+
+func (s *Defensive) Name() string { return "defensive" }
+
+func (s *Defensive) Next(v View, rng *rand.Rand) []string {
+	enemies := v.EnemyUnits()
+	units := sortedUnits(v.Player)
+	spots := v.spawnSpots()
+	if s.home == "" || !slices.Contains(spots, s.home) || enemies[s.home] > 0 {
+		s.home = safest(enemies, spots, rng)
+	}
+
+	if words := toward(v.Map, units, s.home); words != nil {
+		return words
+	}
+	if len(units) < s.MaxUnits {
+		return spawn(s.home, gamelogic.RankInfantry)
+	}
+	return nil
+}
+
+// weakest picks the location with the fewest enemy units
+func weakest(enemies map[gamelogic.Location]int, locations []gamelogic.Location) (gamelogic.Location, bool) {
+	var best gamelogic.Location
+	found := false
+	for _, loc := range locations {
+		count := enemies[loc]
+		if count == 0 {
+			continue
+		}
+		if !found || count < enemies[best] {
+			best, found = loc, true
+		}
+	}
+	return best, found
+}
+
+// safest picks a random location with no known enemies, or the least
+// crowded one if there is none
+func safest(enemies map[gamelogic.Location]int, locations []gamelogic.Location, rng *rand.Rand) gamelogic.Location {
+	empty := []gamelogic.Location{}
+	for _, loc := range locations {
+		if enemies[loc] == 0 {
+			empty = append(empty, loc)
+		}
+	}
+	if len(empty) > 0 {
+		return empty[rng.Intn(len(empty))]
+	}
+	best := locations[0]
+	for _, loc := range locations {
+		if enemies[loc] < enemies[best] {
+			best = loc
+		}
+	}
+	return best
+}
+
+// toward moves units along the shortest route to target, as far as they
+// can get in one move. The first unit not yet there picks the stop, and
+// every other unit with the same stop comes along.
+func toward(m *gamelogic.Map, units []gamelogic.Unit, target gamelogic.Location) []string {
+	var stop gamelogic.Location
+	group := []gamelogic.Unit{}
+	for _, unit := range units {
+		path := m.Path(unit.Location, target)
+		if len(path) == 0 {
+			continue
+		}
+		step := path[min(gamelogic.MoveRange(unit.Rank), len(path))-1]
+		if stop == "" {
+			stop = step
+		}
+		if step == stop {
+			group = append(group, unit)
+		}
+	}
+	if len(group) == 0 {
+		return nil
+	}
+	return move(stop, group)
+}
+
+// spawnSpots lists where the player may spawn right now. A first unit
+// must go on one of the map's starting positions, if it has any. With the
+// economy on, later units go where the server says the player holds
+// territory, or until it has heard of any, where the player has units.
+func (v View) spawnSpots() []gamelogic.Location {
+	if starts := v.Map.StartingPositions(); len(starts) > 0 && len(v.Player.Units) == 0 {
+		return starts
+	}
+	if !gamelogic.EconomyEnabled || len(v.Player.Units) == 0 {
+		return v.Map.Locations()
+	}
+
+	spots := slices.Clone(v.Held)
+	if len(spots) == 0 {
+		for _, u := range v.Player.Units {
+			if !slices.Contains(spots, u.Location) {
+				spots = append(spots, u.Location)
+			}
+		}
+	}
+	sort.Slice(spots, func(i, j int) bool { return spots[i] < spots[j] })
+	return spots
+}
+
+func sortedUnits(p gamelogic.Player) []gamelogic.Unit {
+	units := make([]gamelogic.Unit, 0, len(p.Units))
+	for _, unit := range p.Units {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].ID < units[j].ID })
+	return units
+}
+
+func spawn(loc gamelogic.Location, rank gamelogic.UnitRank) []string {
+	return []string{"spawn", string(loc), string(rank)}
+}
+
+func move(loc gamelogic.Location, units []gamelogic.Unit) []string {
+	words := []string{"move", string(loc)}
+	for _, unit := range units {
+		words = append(words, strconv.Itoa(unit.ID))
+	}
+	return words
+}
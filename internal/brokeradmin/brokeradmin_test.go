@@ -0,0 +1,167 @@
+package brokeradmin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type request struct {
+	method string
+	path   string
+	user   string
+	pass   string
+	body   map[string]string
+}
+
+// fakeManagement records every request and answers with status
+func fakeManagement(t *testing.T, status int) (*httptest.Server, func() []request) {
+	t.Helper()
+	var mu sync.Mutex
+	requests := []request{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		req := request{method: r.Method, path: r.URL.EscapedPath(), user: user, pass: pass}
+		if data, _ := io.ReadAll(r.Body); len(data) > 0 {
+			if err := json.Unmarshal(data, &req.body); err != nil {
+				t.Errorf("bad request body %q: %v", data, err)
+			}
+		}
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+
+		w.WriteHeader(status)
+		if status >= 300 {
+			io.WriteString(w, `{"error":"not_authorised"}`)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request{}, requests...)
+	}
+}
+
+func TestProvisionPlayer(t *testing.T) {
+	srv, requests := fakeManagement(t, http.StatusNoContent)
+	c := NewClient(srv.URL+"/", "admin", "secret")
+
+	if err := c.ProvisionPlayer("/", "alice", "s3cret"); err != nil {
+		t.Fatalf("ProvisionPlayer: %v", err)
+	}
+
+	got := requests()
+	want := []struct{ method, path string }{
+		{http.MethodPut, "/api/users/alice"},
+		{http.MethodPut, "/api/permissions/%2F/alice"},
+		{http.MethodPut, "/api/topic-permissions/%2F/alice"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d requests, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		if got[i].method != w.method || got[i].path != w.path {
+			t.Errorf("request %d = %s %s, want %s %s", i, got[i].method, got[i].path, w.method, w.path)
+		}
+		if got[i].user != "admin" || got[i].pass != "secret" {
+			t.Errorf("request %d authenticated as %s:%s", i, got[i].user, got[i].pass)
+		}
+	}
+	if got[0].body["password"] != "s3cret" {
+		t.Errorf("user password = %q, want s3cret", got[0].body["password"])
+	}
+	if got[2].body["exchange"] != "peril_topic" {
+		t.Errorf("topic permissions set on %q, want peril_topic", got[2].body["exchange"])
+	}
+}
+
+func TestDeprovisionPlayer(t *testing.T) {
+	srv, requests := fakeManagement(t, http.StatusNoContent)
+	c := NewClient(srv.URL, "admin", "secret")
+
+	if err := c.DeprovisionPlayer("alice"); err != nil {
+		t.Fatalf("DeprovisionPlayer: %v", err)
+	}
+	got := requests()
+	if len(got) != 1 || got[0].method != http.MethodDelete || got[0].path != "/api/users/alice" {
+		t.Fatalf("got requests %+v, want DELETE /api/users/alice", got)
+	}
+}
+
+func TestProvisionPlayerReportsErrors(t *testing.T) {
+	srv, requests := fakeManagement(t, http.StatusUnauthorized)
+	c := NewClient(srv.URL, "admin", "wrong")
+
+	err := c.ProvisionPlayer("/", "alice", "s3cret")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "not_authorised") {
+		t.Errorf("error %q does not carry the status and body", err)
+	}
+	if n := len(requests()); n != 1 {
+		t.Errorf("sent %d requests after the first failed, want 1", n)
+	}
+}
+
+func TestPlayerPermissions(t *testing.T) {
+	perms, topic := PlayerPermissions("alice")
+	if len(topic) != 1 {
+		t.Fatalf("got %d topic permissions, want 1", len(topic))
+	}
+
+	tests := []struct {
+		name    string
+		pattern string
+		input   string
+		want    bool
+	}{
+		{"write own queue", perms.Write, "army_moves.alice", true},
+		{"write other's queue", perms.Write, "army_moves.bob", false},
+		{"write topic exchange", perms.Write, "peril_topic", true},
+		{"write direct exchange", perms.Write, "peril_direct", false},
+		{"read direct exchange", perms.Read, "peril_direct", true},
+		{"configure own war queue", perms.Configure, "war.alice", true},
+		{"configure shared war queue", perms.Configure, "war", false},
+		{"publish own move", topic[0].Write, "army_moves.alice", true},
+		{"publish other's move", topic[0].Write, "army_moves.bob", false},
+		{"publish war", topic[0].Write, "war.alice", false},
+		{"publish own war result", topic[0].Write, "war_results.alice", true},
+		{"publish login", topic[0].Write, "login", true},
+		{"publish spawn", topic[0].Write, "spawn", true},
+		{"publish army", topic[0].Write, "army", true},
+		{"publish session event", topic[0].Write, "sessions.alice", false},
+		{"publish verified move", topic[0].Write, "verified.army_moves.alice", false},
+		{"bind verified moves", topic[0].Read, "verified.army_moves.*", true},
+		{"bind raw moves", topic[0].Read, "army_moves.*", false},
+		{"bind own wars", topic[0].Read, "verified.war.alice", true},
+		{"bind other's wars", topic[0].Read, "verified.war.bob", false},
+		{"bind every war", topic[0].Read, "verified.war.*", false},
+		{"bind territory", topic[0].Read, "verified.territory.*", true},
+		{"bind raw territory", topic[0].Read, "territory.*", false},
+		{"publish territory", topic[0].Write, "territory.alice", false},
+		{"bind everything", topic[0].Read, "#", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := regexp.MustCompile(tt.pattern).MatchString(tt.input)
+			if got != tt.want {
+				t.Errorf("%q matches %q = %v, want %v", tt.pattern, tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlayerPermissionsQuoteUsername(t *testing.T) {
+	_, topic := PlayerPermissions("a+b")
+	if regexp.MustCompile(topic[0].Write).MatchString("army_moves.aab") {
+		t.Fatal("username was used as a regular expression")
+	}
+}
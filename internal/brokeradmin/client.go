@@ -0,0 +1,101 @@
+package brokeradmin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultManagementURL = "http://localhost:15672"
+
+// Client talks to the RabbitMQ management HTTP API
+type Client struct {
+	BaseURL  string
+	Username string
+	Password string
+	HTTP     *http.Client
+}
+
+func NewClient(baseURL, username, password string) *Client {
+	return &Client{
+		BaseURL:  strings.TrimRight(baseURL, "/"),
+		Username: username,
+		Password: password,
+		HTTP:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type User struct {
+	Password string `json:"password"`
+	Tags     string `json:"tags"`
+}
+
+// Permissions are regular expressions matched against resource names
+type Permissions struct {
+	Configure string `json:"configure"`
+	Write     string `json:"write"`
+	Read      string `json:"read"`
+}
+
+// TopicPermissions are regular expressions matched against routing keys
+// on a topic exchange
+type TopicPermissions struct {
+	Exchange string `json:"exchange"`
+	Write    string `json:"write"`
+	Read     string `json:"read"`
+}
+
+func (c *Client) PutUser(username string, user User) error {
+	return c.do(http.MethodPut, "/api/users/"+url.PathEscape(username), user)
+}
+
+func (c *Client) DeleteUser(username string) error {
+	return c.do(http.MethodDelete, "/api/users/"+url.PathEscape(username), nil)
+}
+
+func (c *Client) SetPermissions(vhost, username string, perms Permissions) error {
+	path := fmt.Sprintf("/api/permissions/%s/%s", url.PathEscape(vhost), url.PathEscape(username))
+	return c.do(http.MethodPut, path, perms)
+}
+
+func (c *Client) SetTopicPermissions(vhost, username string, perms TopicPermissions) error {
+	path := fmt.Sprintf("/api/topic-permissions/%s/%s", url.PathEscape(vhost), url.PathEscape(username))
+	return c.do(http.MethodPut, path, perms)
+}
+
+func (c *Client) do(method, path string, body any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.BaseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.SetBasicAuth(c.Username, c.Password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
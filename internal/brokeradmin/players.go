@@ -0,0 +1,102 @@
+package brokeradmin
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+// PlayerPermissions limits a player's broker user to its own queues and to
+// routing keys ending in its own username, so the broker enforces the
+// identity the game otherwise takes on trust
+func PlayerPermissions(username string) (Permissions, []TopicPermissions) {
+	u := regexp.QuoteMeta(username)
+
+	ownQueues := fmt.Sprintf(`^(%s|%s|%s|%s|%s|%s|%s|%s)\.%s$`, routing.PauseKey, routing.MapChangedKey, routing.TurnKey, routing.EconomyKey, routing.ArmyMovesPrefix, routing.WarRecognitionsPrefix, routing.WarResultsPrefix, routing.TerritoryPrefix, u)
+	exchanges := fmt.Sprintf(`^(%s|%s)$`,
+		regexp.QuoteMeta(routing.ExchangePerilDirect),
+		regexp.QuoteMeta(routing.ExchangePerilTopic),
+	)
+	// Only the server publishes on peril_direct: pauses, maps, turns and
+	// balances. Players send everything, RPCs included, on peril_topic,
+	// where topic permissions limit the routing keys.
+	topicExchange := fmt.Sprintf(`^%s$`, regexp.QuoteMeta(routing.ExchangePerilTopic))
+	// Declaring a queue with a dead letter exchange needs write access to it
+	deadLetter := fmt.Sprintf(`^%s$`, regexp.QuoteMeta(routing.ExchangePerilDeadLetter))
+
+	perms := Permissions{
+		Configure: ownQueues,
+		Write:     anyOf(ownQueues, topicExchange, deadLetter),
+		Read:      anyOf(ownQueues, exchanges),
+	}
+
+	topic := []TopicPermissions{
+		{
+			Exchange: routing.ExchangePerilTopic,
+			Write: anyOf(
+				fmt.Sprintf(`^(%s|%s|%s)\.%s$`,
+					routing.ArmyMovesPrefix,
+					routing.WarResultsPrefix,
+					routing.GameLogSlug,
+					u,
+				),
+				fmt.Sprintf(`^(%s|%s|%s|%s|%s|%s)$`,
+					routing.LoginKey,
+					routing.LogoutKey,
+					routing.MapKey,
+					routing.TurnOrderKey,
+					routing.SpawnKey,
+					routing.ArmyKey,
+				),
+			),
+			// Read is checked against binding keys. Moves and war results
+			// are only read once the server has relayed them, so players
+			// cannot bind to raw messages and see each other's session
+			// tokens. Control changes only come from the server. Each
+			// player only reads the wars the server sends it to resolve.
+			Read: fmt.Sprintf(`^%s\.(%s|%s|%s)\.\*$|^%s\.%s\.%s$`,
+				routing.VerifiedPrefix,
+				routing.ArmyMovesPrefix,
+				routing.WarResultsPrefix,
+				routing.TerritoryPrefix,
+				routing.VerifiedPrefix,
+				routing.WarRecognitionsPrefix,
+				u,
+			),
+		},
+	}
+	return perms, topic
+}
+
+// ProvisionPlayer creates or updates the broker user for a player and
+// scopes its permissions to that player
+func (c *Client) ProvisionPlayer(vhost, username, password string) error {
+	if err := c.PutUser(username, User{Password: password}); err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	perms, topic := PlayerPermissions(username)
+	if err := c.SetPermissions(vhost, username, perms); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	for _, tp := range topic {
+		if err := c.SetTopicPermissions(vhost, username, tp); err != nil {
+			return fmt.Errorf("failed to set topic permissions on %s: %w", tp.Exchange, err)
+		}
+	}
+	return nil
+}
+
+// DeprovisionPlayer deletes the broker user along with its permissions
+func (c *Client) DeprovisionPlayer(username string) error {
+	if err := c.DeleteUser(username); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
+
+func anyOf(patterns ...string) string {
+	return strings.Join(patterns, "|")
+}
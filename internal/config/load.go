@@ -0,0 +1,276 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Role selects which settings a binary exposes
+type Role int
+
+const (
+	RoleClient Role = 1 << iota
+	RoleServer
+	RoleBot
+	RoleLoadTest
+
+	roleAll    = RoleClient | RoleServer | RoleBot | RoleLoadTest
+	rolePlayer = RoleClient | RoleBot
+)
+
+// ConfigEnv names the config file when -config is not given
+const ConfigEnv = "PERIL_CONFIG"
+
+// setting ties one field of Config to its environment variable and flag
+type setting struct {
+	flag  string
+	env   []string
+	usage string
+	roles Role
+	value func(c *Config) flag.Value
+}
+
+func settings() []setting {
+	return []setting{
+		{"broker-url", []string{"PERIL_BROKER_URL", "RABBITMQ_URL"}, "RabbitMQ URL", roleAll, func(c *Config) flag.Value { return (*stringValue)(&c.Broker.URL) }},
+		{"tls-ca", []string{"PERIL_TLS_CA"}, "PEM bundle of CAs trusted for the broker certificate", roleAll, func(c *Config) flag.Value { return (*stringValue)(&c.Broker.TLS.CAFile) }},
+		{"tls-cert", []string{"PERIL_TLS_CERT"}, "client certificate for mutual TLS", roleAll, func(c *Config) flag.Value { return (*stringValue)(&c.Broker.TLS.CertFile) }},
+		{"tls-key", []string{"PERIL_TLS_KEY"}, "client key for mutual TLS", roleAll, func(c *Config) flag.Value { return (*stringValue)(&c.Broker.TLS.KeyFile) }},
+		{"tls-server-name", []string{"PERIL_TLS_SERVER_NAME"}, "host name to verify the broker certificate against", roleAll, func(c *Config) flag.Value { return (*stringValue)(&c.Broker.TLS.ServerName) }},
+		{"tls-external-auth", []string{"PERIL_TLS_EXTERNAL_AUTH"}, "authenticate with the client certificate (SASL EXTERNAL)", roleAll, func(c *Config) flag.Value { return (*boolValue)(&c.Broker.TLS.ExternalAuth) }},
+		{"tls-insecure", []string{"PERIL_TLS_INSECURE"}, "skip broker certificate verification (testing only)", roleAll, func(c *Config) flag.Value { return (*boolValue)(&c.Broker.TLS.InsecureSkipVerify) }},
+
+		{"exchange-direct", []string{"PERIL_EXCHANGE_DIRECT"}, "direct exchange name", roleAll, func(c *Config) flag.Value { return (*stringValue)(&c.Exchanges.Direct) }},
+		{"exchange-topic", []string{"PERIL_EXCHANGE_TOPIC"}, "topic exchange name", roleAll, func(c *Config) flag.Value { return (*stringValue)(&c.Exchanges.Topic) }},
+		{"exchange-dead-letter", []string{"PERIL_EXCHANGE_DEAD_LETTER"}, "dead letter exchange name", roleAll, func(c *Config) flag.Value { return (*stringValue)(&c.Exchanges.DeadLetter) }},
+		{"queue-type", []string{"PERIL_QUEUE_TYPE"}, "type of durable queues, quorum or classic", roleAll, func(c *Config) flag.Value { return (*stringValue)(&c.Queues.DurableType) }},
+		{"prefetch", []string{"PERIL_PREFETCH"}, "unacknowledged messages per consumer", roleAll, func(c *Config) flag.Value { return (*intValue)(&c.Queues.Prefetch) }},
+		{"combat", []string{"PERIL_COMBAT"}, "how the wars the server declares are resolved, rounds or dice", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Game.Combat) }},
+		{"coalition", []string{"PERIL_COALITION"}, "defenders in the wars the server declares fight as one team", RoleServer, func(c *Config) flag.Value { return (*boolValue)(&c.Game.Coalition) }},
+		{"turns", []string{"PERIL_TURNS"}, "turn mode, off, simultaneous or sequential", RoleServer | rolePlayer, func(c *Config) flag.Value { return (*stringValue)(&c.Game.Turns) }},
+		{"turn-length", []string{"PERIL_TURN_LENGTH"}, "how long each turn lasts", RoleServer, func(c *Config) flag.Value { return (*durationValue)(&c.Game.TurnLength) }},
+		{"economy", []string{"PERIL_ECONOMY"}, "make spawns cost resources earned from territory", RoleServer | rolePlayer, func(c *Config) flag.Value { return (*boolValue)(&c.Game.Economy) }},
+		{"income-interval", []string{"PERIL_INCOME_INTERVAL"}, "how often income is paid when turns are off", RoleServer, func(c *Config) flag.Value { return (*durationValue)(&c.Game.IncomeInterval) }},
+		{"map", []string{"PERIL_MAP"}, "built-in map (classic or risk) or map file", RoleServer | RoleLoadTest, func(c *Config) flag.Value { return (*stringValue)(&c.Game.Map) }},
+
+		{"log-path", []string{"PERIL_LOG_PATH"}, "game log file, with the instance ID added in shard mode", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Log.Path) }},
+		{"log-format", []string{"PERIL_LOG_FORMAT"}, "game log format, jsonl or text", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Log.Format) }},
+		{"log-max-size", []string{"PERIL_LOG_MAX_SIZE"}, "rotate the game log past this many bytes", RoleServer, func(c *Config) flag.Value { return (*int64Value)(&c.Log.MaxSize) }},
+		{"log-max-age", []string{"PERIL_LOG_MAX_AGE"}, "rotate the game log after this long", RoleServer, func(c *Config) flag.Value { return (*durationValue)(&c.Log.MaxAge) }},
+		{"log-max-backups", []string{"PERIL_LOG_MAX_BACKUPS"}, "rotated game logs to keep, 0 keeps all", RoleServer, func(c *Config) flag.Value { return (*intValue)(&c.Log.MaxBackups) }},
+		{"log-sync", []string{"PERIL_LOG_SYNC"}, "fsync policy, always, interval or never", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Log.Sync) }},
+		{"log-sync-interval", []string{"PERIL_LOG_SYNC_INTERVAL"}, "fsync interval for the interval policy", RoleServer, func(c *Config) flag.Value { return (*durationValue)(&c.Log.SyncInterval) }},
+		{"log-mode", []string{"PERIL_LOG_MODE"}, "how servers share game logs, shard or single", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Log.Mode) }},
+		{"log-db", []string{"PERIL_LOG_DB"}, "SQLite database of game logs", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Log.Database) }},
+
+		{"instance", []string{"PERIL_INSTANCE_ID"}, "server instance ID, defaults to <hostname>-<pid>", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Server.InstanceID) }},
+		{"users-file", []string{"PERIL_USERS_FILE"}, "bcrypt user store", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Server.UsersFile) }},
+		{"session-secret", []string{"PERIL_SESSION_SECRET"}, "secret shared by server instances to sign session events", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Server.SessionSecret) }},
+		{"economy-file", []string{"PERIL_ECONOMY_FILE"}, "where balances are kept", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Server.EconomyFile) }},
+		{"board-file", []string{"PERIL_BOARD_FILE"}, "where armies and territory control are kept", RoleServer, func(c *Config) flag.Value { return (*stringValue)(&c.Server.BoardFile) }},
+		{"log-rate", []string{"PERIL_SERVER_LOG_RATE"}, "game logs accepted per player per second, 0 disables", RoleServer, func(c *Config) flag.Value { return (*floatValue)(&c.Server.LogRate) }},
+		{"log-burst", []string{"PERIL_SERVER_LOG_BURST"}, "game log burst per player", RoleServer, func(c *Config) flag.Value { return (*intValue)(&c.Server.LogBurst) }},
+		{"move-rate", []string{"PERIL_SERVER_MOVE_RATE"}, "moves relayed per player per second, 0 disables", RoleServer, func(c *Config) flag.Value { return (*floatValue)(&c.Server.MoveRate) }},
+		{"move-burst", []string{"PERIL_SERVER_MOVE_BURST"}, "move burst per player", RoleServer, func(c *Config) flag.Value { return (*intValue)(&c.Server.MoveBurst) }},
+
+		{"log-rate", []string{"PERIL_LOG_RATE"}, "game logs published per second, 0 disables", RoleClient, func(c *Config) flag.Value { return (*floatValue)(&c.Client.LogRate) }},
+		{"log-burst", []string{"PERIL_LOG_BURST"}, "game log publish burst", RoleClient, func(c *Config) flag.Value { return (*intValue)(&c.Client.LogBurst) }},
+		{"move-rate", []string{"PERIL_MOVE_RATE"}, "moves per second, 0 disables", RoleClient, func(c *Config) flag.Value { return (*floatValue)(&c.Client.MoveRate) }},
+		{"move-burst", []string{"PERIL_MOVE_BURST"}, "move burst", RoleClient, func(c *Config) flag.Value { return (*intValue)(&c.Client.MoveBurst) }},
+		{"blocked-policy", []string{"PERIL_BLOCKED_POLICY"}, "publishes while the broker is blocked, fail or buffer", RoleClient, func(c *Config) flag.Value { return (*stringValue)(&c.Client.BlockedPolicy) }},
+		{"publish-buffer", []string{"PERIL_PUBLISH_BUFFER"}, "messages buffered while the broker is blocked", RoleClient, func(c *Config) flag.Value { return (*intValue)(&c.Client.PublishBuffer) }},
+		{"username", []string{"PERIL_USERNAME"}, "log in as this player without prompting", rolePlayer, func(c *Config) flag.Value { return (*stringValue)(&c.Client.Username) }},
+		{"password", []string{"PERIL_PASSWORD"}, "password for -username", rolePlayer, func(c *Config) flag.Value { return (*stringValue)(&c.Client.Password) }},
+		{"script", nil, "run the commands in this file instead of prompting", RoleClient, func(c *Config) flag.Value { return (*stringValue)(&c.Client.Script) }},
+		{"exec", nil, "run these ;-separated commands instead of prompting", RoleClient, func(c *Config) flag.Value { return (*stringValue)(&c.Client.Exec) }},
+		{"script-timeout", []string{"PERIL_SCRIPT_TIMEOUT"}, "how long a script wait may take", RoleClient, func(c *Config) flag.Value { return (*durationValue)(&c.Client.ScriptTimeout) }},
+
+		{"strategy", []string{"PERIL_BOT_STRATEGY"}, "bot strategy, random, aggressive or defensive", RoleBot, func(c *Config) flag.Value { return (*stringValue)(&c.Bot.Strategy) }},
+		{"interval", []string{"PERIL_BOT_INTERVAL"}, "time between bot turns", RoleBot, func(c *Config) flag.Value { return (*durationValue)(&c.Bot.Interval) }},
+		{"seed", []string{"PERIL_BOT_SEED"}, "random seed, 0 picks one from the clock", RoleBot, func(c *Config) flag.Value { return (*int64Value)(&c.Bot.Seed) }},
+
+		{"players", []string{"PERIL_LOAD_PLAYERS"}, "simulated players", RoleLoadTest, func(c *Config) flag.Value { return (*intValue)(&c.LoadTest.Players) }},
+		{"connections", []string{"PERIL_LOAD_CONNECTIONS"}, "broker connections the players are spread over", RoleLoadTest, func(c *Config) flag.Value { return (*intValue)(&c.LoadTest.Connections) }},
+		{"move-rate", []string{"PERIL_LOAD_MOVE_RATE"}, "moves per player per second", RoleLoadTest, func(c *Config) flag.Value { return (*floatValue)(&c.LoadTest.MoveRate) }},
+		{"spawn-rate", []string{"PERIL_LOAD_SPAWN_RATE"}, "spawns per player per second", RoleLoadTest, func(c *Config) flag.Value { return (*floatValue)(&c.LoadTest.SpawnRate) }},
+		{"duration", []string{"PERIL_LOAD_DURATION"}, "how long to run", RoleLoadTest, func(c *Config) flag.Value { return (*durationValue)(&c.LoadTest.Duration) }},
+		{"sample-interval", []string{"PERIL_LOAD_SAMPLE_INTERVAL"}, "how often to report progress and sample queue depth", RoleLoadTest, func(c *Config) flag.Value { return (*durationValue)(&c.LoadTest.SampleInterval) }},
+		{"prefix", []string{"PERIL_LOAD_PREFIX"}, "username prefix of simulated players", RoleLoadTest, func(c *Config) flag.Value { return (*stringValue)(&c.LoadTest.Prefix) }},
+		{"password", []string{"PERIL_LOAD_PASSWORD"}, "password of every simulated player", RoleLoadTest, func(c *Config) flag.Value { return (*stringValue)(&c.LoadTest.Password) }},
+		{"users-file", []string{"PERIL_LOAD_USERS_FILE"}, "add the simulated players to this server user file first", RoleLoadTest, func(c *Config) flag.Value { return (*stringValue)(&c.LoadTest.UsersFile) }},
+	}
+}
+
+// Load builds the configuration for role from, lowest precedence first:
+// built-in defaults, the config file, environment variables and flags.
+// The config file is YAML or TOML, chosen by extension, and is named by
+// -config or PERIL_CONFIG.
+func Load(role Role, name string, args []string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(ConfigEnv), "YAML or TOML config file")
+	active := []setting{}
+	for _, s := range settings() {
+		if s.roles&role == 0 {
+			continue
+		}
+		active = append(active, s)
+		fs.Var(s.value(&cfg), s.flag, s.usage)
+	}
+
+	// Flags are parsed first to find the config file, then replayed on top
+	// of the file and environment so they keep the highest precedence
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	setFlags := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = f.Value.String()
+	})
+
+	cfg = Default()
+	if *configPath != "" {
+		if err := loadFile(*configPath, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range active {
+		if err := applyEnv(s, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range active {
+		v, ok := setFlags[s.flag]
+		if !ok {
+			continue
+		}
+		if err := s.value(&cfg).Set(v); err != nil {
+			return Config{}, fmt.Errorf("-%s: %w", s.flag, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// applyEnv uses the first of s's environment variables that is set
+func applyEnv(s setting, cfg *Config) error {
+	for _, key := range s.env {
+		v, ok := os.LookupEnv(key)
+		if !ok || v == "" {
+			continue
+		}
+		if err := s.value(cfg).Set(v); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		return nil
+	}
+	return nil
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config: %w", err)
+	}
+
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("config %s: unknown extension %q, use .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+// IsHelp reports whether Load failed because -h or -help was given
+func IsHelp(err error) bool {
+	return errors.Is(err, flag.ErrHelp)
+}
+
+type stringValue string
+
+func (v *stringValue) Set(s string) error { *v = stringValue(s); return nil }
+func (v *stringValue) String() string     { return string(*v) }
+
+type boolValue bool
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+func (v *boolValue) String() string   { return strconv.FormatBool(bool(*v)) }
+func (v *boolValue) IsBoolFlag() bool { return true }
+
+type intValue int
+
+func (v *intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return err
+	}
+	*v = intValue(i)
+	return nil
+}
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type int64Value int64
+
+func (v *int64Value) Set(s string) error {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = int64Value(i)
+	return nil
+}
+func (v *int64Value) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+type floatValue float64
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*v = floatValue(f)
+	return nil
+}
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+func (v *durationValue) String() string { return time.Duration(*v).String() }
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every variable Load reads so the host environment cannot
+// leak into a test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	keys := []string{ConfigEnv}
+	for _, s := range settings() {
+		keys = append(keys, s.env...)
+	}
+	for _, key := range keys {
+		if v, ok := os.LookupEnv(key); ok {
+			os.Unsetenv(key)
+			t.Cleanup(func() { os.Setenv(key, v) })
+		}
+	}
+}
+
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeConfig(t, "peril.yaml", "log:\n  path: file.log\n  max_age: 2h\nserver:\n  log_rate: 3\n")
+	tomlFile := writeConfig(t, "peril.toml", "[log]\npath = \"file.log\"\nmax_age = \"2h\"\n[server]\nlog_rate = 3.0\n")
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		args     []string
+		wantPath string
+		wantAge  time.Duration
+		wantRate float64
+	}{
+		{"defaults", nil, nil, "game.log", 24 * time.Hour, 1},
+		{"yaml file", nil, []string{"-config", yamlFile}, "file.log", 2 * time.Hour, 3},
+		{"toml file", nil, []string{"-config", tomlFile}, "file.log", 2 * time.Hour, 3},
+		{"file from env", map[string]string{ConfigEnv: yamlFile}, nil, "file.log", 2 * time.Hour, 3},
+		{"env over file", map[string]string{"PERIL_LOG_PATH": "env.log"}, []string{"-config", yamlFile}, "env.log", 2 * time.Hour, 3},
+		{"flag over env", map[string]string{"PERIL_LOG_PATH": "env.log"}, []string{"-config", yamlFile, "-log-path", "flag.log"}, "flag.log", 2 * time.Hour, 3},
+		{"flag before -config", nil, []string{"-log-max-age", "1m", "-config", yamlFile}, "file.log", time.Minute, 3},
+		{"empty env is unset", map[string]string{"PERIL_SERVER_LOG_RATE": ""}, []string{"-config", yamlFile}, "file.log", 2 * time.Hour, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := Load(RoleServer, "server", tt.args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Log.Path != tt.wantPath || cfg.Log.MaxAge != tt.wantAge || cfg.Server.LogRate != tt.wantRate {
+				t.Fatalf("got path %q, max age %v, log rate %v", cfg.Log.Path, cfg.Log.MaxAge, cfg.Server.LogRate)
+			}
+		})
+	}
+}
+
+func TestLoadEnvFallback(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("RABBITMQ_URL", "amqp://fallback/")
+	cfg, err := Load(RoleServer, "server", nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Broker.URL != "amqp://fallback/" {
+		t.Fatalf("broker url = %q, want the RABBITMQ_URL fallback", cfg.Broker.URL)
+	}
+
+	t.Setenv("PERIL_BROKER_URL", "amqp://peril/")
+	cfg, err = Load(RoleServer, "server", nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Broker.URL != "amqp://peril/" {
+		t.Fatalf("broker url = %q, want PERIL_BROKER_URL first", cfg.Broker.URL)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+	}{
+		{"flag of another role", nil, []string{"-strategy", "aggressive"}},
+		{"bad env value", map[string]string{"PERIL_LOG_MAX_AGE": "soon"}, nil},
+		{"bad flag value", nil, []string{"-log-max-backups", "many"}},
+		{"stray argument", nil, []string{"extra"}},
+		{"unknown extension", nil, []string{"-config", writeConfig(t, "peril.ini", "")}},
+		{"missing file", nil, []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}},
+		{"invalid result", nil, []string{"-log-mode", "both"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, err := Load(RoleServer, "server", tt.args); err == nil {
+				t.Fatal("Load succeeded")
+			}
+		})
+	}
+
+	clearEnv(t)
+	if _, err := Load(RoleServer, "server", []string{"-h"}); !IsHelp(err) {
+		t.Fatalf("-h gave %v, want a help error", err)
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// TLSConfig builds the client TLS settings for the broker. It returns nil
+// for a plain amqp:// URL, and an error for settings Validate refuses.
+func (b Broker) TLSConfig() (*tls.Config, error) {
+	u, err := url.Parse(b.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid broker url: %w", err)
+	}
+	if err := b.validateTLS(); err != nil {
+		return nil, err
+	}
+	if u.Scheme != "amqps" {
+		return nil, nil
+	}
+
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         b.TLS.ServerName,
+		InsecureSkipVerify: b.TLS.InsecureSkipVerify,
+	}
+
+	if b.TLS.CAFile != "" {
+		pem, err := os.ReadFile(b.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", b.TLS.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if b.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(b.TLS.CertFile, b.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// Dial connects to the broker, over TLS for an amqps:// URL
+func (b Broker) Dial() (*amqp.Connection, error) {
+	tlsConfig, err := b.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil {
+		return amqp.Dial(b.URL)
+	}
+	if b.TLS.ExternalAuth {
+		return amqp.DialTLS_ExternalAuth(b.URL, tlsConfig)
+	}
+	return amqp.DialTLS(b.URL, tlsConfig)
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// certAuthority signs the certificates for a test
+type certAuthority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newCertAuthority(t *testing.T, name string) *certAuthority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &certAuthority{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue signs a certificate for name and returns it and its key as PEM
+func (ca *certAuthority) issue(t *testing.T, name string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+// listenBroker stands in for a broker that requires a client certificate
+// signed by ca. It reports the result of each TLS handshake, then hangs up.
+func listenBroker(t *testing.T, ca *certAuthority) (string, <-chan error) {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := x509.NewCertPool()
+	clients.AddCert(ca.cert)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clients,
+	})
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	handshakes := make(chan error, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			handshakes <- conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String(), handshakes
+}
+
+func TestBrokerDialMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCertAuthority(t, "peril test CA")
+	caFile := writeFile(t, dir, "ca.pem", ca.pem)
+	certPEM, keyPEM := ca.issue(t, "player", x509.ExtKeyUsageClientAuth)
+	certFile := writeFile(t, dir, "client.pem", certPEM)
+	keyFile := writeFile(t, dir, "client.key", keyPEM)
+
+	rogue := newCertAuthority(t, "rogue CA")
+	rogueCert, rogueKey := rogue.issue(t, "player", x509.ExtKeyUsageClientAuth)
+	rogueCertFile := writeFile(t, dir, "rogue.pem", rogueCert)
+	rogueKeyFile := writeFile(t, dir, "rogue.key", rogueKey)
+
+	tests := []struct {
+		name     string
+		tls      TLS
+		accepted bool
+	}{
+		{"trusted client certificate", TLS{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}, true},
+		{"no client certificate", TLS{CAFile: caFile}, false},
+		{"client certificate from another CA", TLS{CAFile: caFile, CertFile: rogueCertFile, KeyFile: rogueKeyFile}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, handshakes := listenBroker(t, ca)
+			tt.tls.ServerName = "localhost"
+			b := Broker{URL: "amqps://guest:guest@" + addr + "/", TLS: tt.tls}
+
+			// The stand-in speaks no AMQP, so Dial always fails once the
+			// handshake is over. What counts is whether the broker took
+			// the certificate.
+			if _, err := b.Dial(); err == nil {
+				t.Fatal("Dial succeeded against a listener that hangs up")
+			}
+			select {
+			case err := <-handshakes:
+				if tt.accepted && err != nil {
+					t.Fatalf("broker refused the client: %v", err)
+				}
+				if !tt.accepted && err == nil {
+					t.Fatal("broker accepted the client")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Dial never reached the broker")
+			}
+		})
+	}
+}
+
+func TestBrokerDialVerifiesServer(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCertAuthority(t, "peril test CA")
+	certPEM, keyPEM := ca.issue(t, "player", x509.ExtKeyUsageClientAuth)
+	other := newCertAuthority(t, "other CA")
+	addr, _ := listenBroker(t, ca)
+
+	b := Broker{URL: "amqps://guest:guest@" + addr + "/", TLS: TLS{
+		CAFile:     writeFile(t, dir, "other.pem", other.pem),
+		CertFile:   writeFile(t, dir, "client.pem", certPEM),
+		KeyFile:    writeFile(t, dir, "client.key", keyPEM),
+		ServerName: "localhost",
+	}}
+	_, err := b.Dial()
+	var unknown x509.UnknownAuthorityError
+	if !errors.As(err, &unknown) {
+		t.Fatalf("Dial to a broker signed by an untrusted CA: %v, want an unknown authority error", err)
+	}
+}
+
+func TestBrokerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCertAuthority(t, "peril test CA")
+	certPEM, keyPEM := ca.issue(t, "player", x509.ExtKeyUsageClientAuth)
+	caFile := writeFile(t, dir, "ca.pem", ca.pem)
+	certFile := writeFile(t, dir, "client.pem", certPEM)
+	keyFile := writeFile(t, dir, "client.key", keyPEM)
+
+	tests := []struct {
+		name    string
+		broker  Broker
+		wantNil bool
+		wantErr string
+	}{
+		{"plain url", Broker{URL: "amqp://localhost/"}, true, ""},
+		{"amqps", Broker{URL: "amqps://localhost/", TLS: TLS{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}}, false, ""},
+		{"key without cert", Broker{URL: "amqps://localhost/", TLS: TLS{KeyFile: keyFile}}, false, "together"},
+		{"cert without key", Broker{URL: "amqps://localhost/", TLS: TLS{CertFile: certFile}}, false, "together"},
+		{"external auth without cert", Broker{URL: "amqps://localhost/", TLS: TLS{ExternalAuth: true}}, false, "client certificate"},
+		{"tls settings on amqp", Broker{URL: "amqp://localhost/", TLS: TLS{CAFile: caFile}}, false, "amqps://"},
+		{"missing CA", Broker{URL: "amqps://localhost/", TLS: TLS{CAFile: filepath.Join(dir, "missing.pem")}}, false, "could not read CA bundle"},
+		{"CA without certificates", Broker{URL: "amqps://localhost/", TLS: TLS{CAFile: keyFile}}, false, "no certificates"},
+		{"mismatched key", Broker{URL: "amqps://localhost/", TLS: TLS{CertFile: caFile, KeyFile: keyFile}}, false, "could not load client certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.broker.TLSConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("TLSConfig: %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TLSConfig: %v", err)
+			}
+			if (cfg == nil) != tt.wantNil {
+				t.Fatalf("TLSConfig returned %v", cfg)
+			}
+			if cfg != nil && (cfg.RootCAs == nil || len(cfg.Certificates) != 1) {
+				t.Fatalf("TLSConfig dropped the CA or client certificate: %+v", cfg)
+			}
+		})
+	}
+}
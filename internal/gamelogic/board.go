@@ -0,0 +1,405 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Board is the server's record of every player's army, of who controls
+// each territory and of the wars waiting to be fought. It only changes
+// through spawns the server approved, moves it checked and war results it
+// accepted, so a player's own word for where its units are is never taken.
+type Board struct {
+	mu      *sync.Mutex
+	players map[string]Player
+	control map[Location]string
+	// wars are declared but not yet fought, at most one per location
+	wars map[Location]RecognitionOfWar
+	// deadlines are when the wars stop waiting for their results. A war
+	// without one, such as one saved before deadlines were kept, has
+	// already expired.
+	deadlines map[Location]time.Time
+}
+
+func NewBoard() *Board {
+	return &Board{
+		mu:        &sync.Mutex{},
+		players:   map[string]Player{},
+		control:   map[Location]string{},
+		wars:      map[Location]RecognitionOfWar{},
+		deadlines: map[Location]time.Time{},
+	}
+}
+
+// join returns username's army, adding an empty one for a player seen for
+// the first time
+func (b *Board) join(username string) Player {
+	p, ok := b.players[username]
+	if !ok {
+		p = Player{Username: username, Units: map[int]Unit{}}
+		b.players[username] = p
+	}
+	return p
+}
+
+// Army is a copy of username's units as the board has them
+func (b *Board) Army(username string) Player {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.army(username)
+}
+
+func (b *Board) army(username string) Player {
+	units := map[int]Unit{}
+	for id, u := range b.players[username].Units {
+		units[id] = u
+	}
+	return Player{Username: username, Units: units}
+}
+
+// Owner is who controls loc, or empty if nobody does yet
+func (b *Board) Owner(loc Location) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.control[loc]
+}
+
+// Holdings lists the territories each player with an army controls
+func (b *Board) Holdings() map[string]map[Location]bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	holdings := map[string]map[Location]bool{}
+	for username := range b.players {
+		holdings[username] = map[Location]bool{}
+	}
+	for loc, owner := range b.control {
+		if held, ok := holdings[owner]; ok {
+			held[loc] = true
+		}
+	}
+	return holdings
+}
+
+// Units counts the units of every army on the board
+func (b *Board) Units() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, p := range b.players {
+		n += len(p.Units)
+	}
+	return n
+}
+
+// CheckSpawn reports why username may not spawn unit. Its ID must be new,
+// and a player's first unit must go on one of the map's starting
+// positions, if it has any.
+func (b *Board) CheckSpawn(m *Map, username string, unit Unit) error {
+	if _, ok := getAllRanks()[unit.Rank]; !ok {
+		return fmt.Errorf("%s is not a valid unit", unit.Rank)
+	}
+	if !m.Has(unit.Location) {
+		return fmt.Errorf("%s is not a valid location", unit.Location)
+	}
+	if unit.ID <= 0 {
+		return fmt.Errorf("%d is not a valid unit ID", unit.ID)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	units := b.players[username].Units
+	if _, ok := units[unit.ID]; ok {
+		return fmt.Errorf("unit %d already exists", unit.ID)
+	}
+	if starts := m.StartingPositions(); len(units) == 0 && len(starts) > 0 && !slices.Contains(starts, unit.Location) {
+		return fmt.Errorf("your first unit must start in one of %v", starts)
+	}
+	return nil
+}
+
+// Spawn adds unit to username's army at full health and returns the
+// control changes it causes
+func (b *Board) Spawn(username string, unit Unit) []ControlChange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	unit.HP = MaxHealth(unit.Rank)
+	b.join(username).Units[unit.ID] = unit
+	return b.settle(unit.Location)
+}
+
+// CheckMove returns move as the board has it, or why it may not be made.
+// The units it moves are the board's rather than the message's, and Player
+// is the mover's army once the move is made.
+func (b *Board) CheckMove(m *Map, move ArmyMove) (ArmyMove, error) {
+	if len(move.Units) == 0 {
+		return ArmyMove{}, errors.New("no units to move")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	username := move.Player.Username
+	army := b.army(username)
+	moved := []Unit{}
+	seen := map[int]bool{}
+	for _, u := range move.Units {
+		if seen[u.ID] {
+			continue
+		}
+		seen[u.ID] = true
+		unit, ok := army.Units[u.ID]
+		if !ok {
+			return ArmyMove{}, fmt.Errorf("%s has no unit %d", username, u.ID)
+		}
+		if err := m.CanMove(unit, move.ToLocation); err != nil {
+			return ArmyMove{}, err
+		}
+		unit.Location = move.ToLocation
+		army.Units[unit.ID] = unit
+		moved = append(moved, unit)
+	}
+	return ArmyMove{Player: army, Units: moved, ToLocation: move.ToLocation}, nil
+}
+
+// Move makes a move returned by CheckMove and returns the control changes
+// it causes. Units lost in a war since the move was checked stay lost.
+func (b *Board) Move(move ArmyMove) []ControlChange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	army := b.join(move.Player.Username)
+	touched := []Location{move.ToLocation}
+	for _, u := range move.Units {
+		unit, ok := army.Units[u.ID]
+		if !ok {
+			continue
+		}
+		// Whoever is left behind may now hold the territory alone
+		touched = append(touched, unit.Location)
+		unit.Location = move.ToLocation
+		army.Units[u.ID] = unit
+	}
+	return b.settle(touched...)
+}
+
+// DeclareWars declares a war, with attacker as the attacker, in every
+// location where its units are alongside another player's and no war is
+// waiting already. Everyone with units there defends, the first by name
+// as the defender. Each war waits on the board until its result arrives
+// or until deadline, when it expires and can be declared again, so an
+// attacker that never reports cannot hold a location up.
+func (b *Board) DeclareWars(attacker string, deadline time.Time) []RecognitionOfWar {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireWars()
+	army := b.army(attacker)
+	locs := []Location{}
+	for _, u := range army.Units {
+		if !slices.Contains(locs, u.Location) {
+			locs = append(locs, u.Location)
+		}
+	}
+	slices.Sort(locs)
+
+	wars := []RecognitionOfWar{}
+	for _, loc := range locs {
+		if _, ok := b.wars[loc]; ok {
+			continue
+		}
+		defenders := []Player{}
+		for _, name := range b.occupants(loc) {
+			if name != attacker {
+				defenders = append(defenders, b.army(name))
+			}
+		}
+		if len(defenders) == 0 {
+			continue
+		}
+		rw := NewWar(army, defenders[0], defenders[1:], loc)
+		b.wars[loc] = rw
+		b.deadlines[loc] = deadline
+		wars = append(wars, rw)
+	}
+	return wars
+}
+
+// expireWars forgets the wars whose deadlines have passed
+func (b *Board) expireWars() {
+	now := time.Now()
+	for loc := range b.wars {
+		if !now.Before(b.deadlines[loc]) {
+			delete(b.wars, loc)
+			delete(b.deadlines, loc)
+		}
+	}
+}
+
+// PendingWar is the war attacker was sent to fight in loc, if it has not
+// reported the result yet and the war has not expired
+func (b *Board) PendingWar(attacker string, loc Location) (RecognitionOfWar, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expireWars()
+	rw, ok := b.wars[loc]
+	if !ok || rw.Attacker.Username != attacker {
+		return RecognitionOfWar{}, false
+	}
+	return rw, true
+}
+
+// WithdrawWar forgets the war waiting in loc, such as one that could not
+// be sent to its attacker, so that it can be declared again
+func (b *Board) WithdrawWar(loc Location) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.wars, loc)
+	delete(b.deadlines, loc)
+}
+
+// ApplyWarReport takes the units a war killed off the board, updates the
+// health of the survivors and hands the location to the victor. It returns
+// the control changes the war causes.
+func (b *Board) ApplyWarReport(report WarReport) []ControlChange {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rw, ok := b.wars[report.Location]; ok && rw.Attacker.Username == report.Attacker {
+		delete(b.wars, report.Location)
+		delete(b.deadlines, report.Location)
+	}
+	for _, fate := range report.Fates {
+		army, ok := b.players[fate.Owner]
+		if !ok {
+			continue
+		}
+		unit, ok := army.Units[fate.Unit.ID]
+		if !ok {
+			continue
+		}
+		if fate.Killed {
+			delete(army.Units, unit.ID)
+			continue
+		}
+		unit.HP = fate.Unit.HP
+		army.Units[unit.ID] = unit
+	}
+
+	changes := []ControlChange{}
+	if victor, ok := report.Victor(); ok && report.Location != "" && b.control[report.Location] != victor {
+		changes = append(changes, b.take(report.Location, victor, ControlConquered))
+	}
+	return append(changes, b.settle(report.Location)...)
+}
+
+// occupants lists the players with units in loc, by name
+func (b *Board) occupants(loc Location) []string {
+	names := []string{}
+	for name, p := range b.players {
+		if len(getUnitsAtLocation(p.Units, loc)) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// settle hands each of locs to the player whose units are the only ones
+// there, unless it controls it already. It is called with b.mu held.
+func (b *Board) settle(locs ...Location) []ControlChange {
+	changes := []ControlChange{}
+	seen := map[Location]bool{}
+	for _, loc := range locs {
+		if seen[loc] {
+			continue
+		}
+		seen[loc] = true
+		occupants := b.occupants(loc)
+		if len(occupants) != 1 || b.control[loc] == occupants[0] {
+			continue
+		}
+		changes = append(changes, b.take(loc, occupants[0], ControlOccupied))
+	}
+	return changes
+}
+
+func (b *Board) take(loc Location, owner, reason string) ControlChange {
+	change := ControlChange{Location: loc, Owner: owner, Previous: b.control[loc], Reason: reason}
+	b.control[loc] = owner
+	return change
+}
+
+// savedBoard is the file form of a Board
+type savedBoard struct {
+	Players map[string]Player             `json:"players"`
+	Control map[Location]string           `json:"control"`
+	Wars    map[Location]RecognitionOfWar `json:"wars"`
+	// Deadlines are when each war expires
+	Deadlines map[Location]time.Time `json:"war_deadlines"`
+}
+
+// Save writes the board to path through a temporary file, so a crash never
+// leaves a half written one behind
+func (b *Board) Save(path string) error {
+	b.mu.Lock()
+	data, err := json.MarshalIndent(savedBoard{Players: b.players, Control: b.control, Wars: b.wars, Deadlines: b.deadlines}, "", "  ")
+	b.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("could not encode board: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".board-*")
+	if err != nil {
+		return fmt.Errorf("could not write board: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write board: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write board: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not write board: %w", err)
+	}
+	return nil
+}
+
+// LoadBoard reads a board saved at path, or starts a new one if there is
+// none yet
+func LoadBoard(path string) (*Board, error) {
+	b := NewBoard()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read board: %w", err)
+	}
+
+	var saved savedBoard
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("could not decode board %s: %w", path, err)
+	}
+	for username, p := range saved.Players {
+		if p.Units == nil {
+			p.Units = map[int]Unit{}
+		}
+		p.Username = username
+		b.players[username] = p
+	}
+	for loc, owner := range saved.Control {
+		b.control[loc] = owner
+	}
+	for loc, rw := range saved.Wars {
+		b.wars[loc] = rw
+	}
+	for loc, deadline := range saved.Deadlines {
+		b.deadlines[loc] = deadline
+	}
+	return b, nil
+}
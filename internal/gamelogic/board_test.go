@@ -0,0 +1,262 @@
+package gamelogic
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// lineMap is four territories in a row, starting at either end
+func lineMap(t *testing.T) *Map {
+	t.Helper()
+	m, err := ParseMap([]byte(`
+name: line
+territories: [{name: a}, {name: b}, {name: c}, {name: d}]
+links:
+  - {from: a, to: b}
+  - {from: b, to: c}
+  - {from: c, to: d}
+starting_positions: [a, d]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCheckSpawn(t *testing.T) {
+	m := lineMap(t)
+	b := NewBoard()
+	b.Spawn("alice", Unit{ID: 1, Rank: RankInfantry, Location: "a"})
+
+	tests := []struct {
+		name     string
+		username string
+		unit     Unit
+		wantErr  bool
+	}{
+		{"first unit on a start", "bob", Unit{ID: 1, Rank: RankInfantry, Location: "d"}, false},
+		{"first unit off the starts", "bob", Unit{ID: 1, Rank: RankInfantry, Location: "b"}, true},
+		{"later unit anywhere", "alice", Unit{ID: 2, Rank: RankCavalry, Location: "c"}, false},
+		{"ID in use", "alice", Unit{ID: 1, Rank: RankInfantry, Location: "a"}, true},
+		{"bad ID", "alice", Unit{ID: 0, Rank: RankInfantry, Location: "a"}, true},
+		{"bad rank", "alice", Unit{ID: 2, Rank: "dragon", Location: "a"}, true},
+		{"bad location", "alice", Unit{ID: 2, Rank: RankInfantry, Location: "mars"}, true},
+	}
+	for _, tt := range tests {
+		err := b.CheckSpawn(m, tt.username, tt.unit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckMove(t *testing.T) {
+	m := lineMap(t)
+	b := NewBoard()
+	b.Spawn("alice", Unit{ID: 1, Rank: RankInfantry, Location: "a"})
+	b.Spawn("alice", Unit{ID: 2, Rank: RankCavalry, Location: "a"})
+	b.Spawn("bob", Unit{ID: 1, Rank: RankInfantry, Location: "d"})
+
+	alice := Player{Username: "alice"}
+	tests := []struct {
+		name    string
+		move    ArmyMove
+		wantErr bool
+	}{
+		{"one step", ArmyMove{Player: alice, Units: []Unit{{ID: 1}}, ToLocation: "b"}, false},
+		{"cavalry two steps", ArmyMove{Player: alice, Units: []Unit{{ID: 2}}, ToLocation: "c"}, false},
+		{"infantry two steps", ArmyMove{Player: alice, Units: []Unit{{ID: 1}}, ToLocation: "c"}, true},
+		// The message says the unit is next door, but the board knows better
+		{"claimed location", ArmyMove{Player: alice, Units: []Unit{{ID: 1, Location: "b"}}, ToLocation: "c"}, true},
+		{"unknown unit", ArmyMove{Player: alice, Units: []Unit{{ID: 3}}, ToLocation: "b"}, true},
+		{"no units", ArmyMove{Player: alice, ToLocation: "b"}, true},
+		{"same place", ArmyMove{Player: alice, Units: []Unit{{ID: 1}}, ToLocation: "a"}, true},
+	}
+	for _, tt := range tests {
+		_, err := b.CheckMove(m, tt.move)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// A checked move carries the board's units, not the message's
+	move := ArmyMove{Player: alice, Units: []Unit{{ID: 1, Rank: RankArtillery}, {ID: 1}}, ToLocation: "b"}
+	checked, err := b.CheckMove(m, move)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Unit{ID: 1, Rank: RankInfantry, Location: "b", HP: MaxHealth(RankInfantry)}
+	if len(checked.Units) != 1 || checked.Units[0] != want {
+		t.Fatalf("checked units %+v, want [%+v]", checked.Units, want)
+	}
+	if checked.Player.Units[1] != want || len(checked.Player.Units) != 2 {
+		t.Fatalf("checked army %+v, want unit 1 moved", checked.Player.Units)
+	}
+	if b.Army("alice").Units[1].Location != "a" {
+		t.Fatal("checking a move made it")
+	}
+}
+
+func TestBoardControl(t *testing.T) {
+	m := lineMap(t)
+	b := NewBoard()
+	if changes := b.Spawn("alice", Unit{ID: 1, Rank: RankInfantry, Location: "a"}); len(changes) != 1 || changes[0].Owner != "alice" {
+		t.Fatalf("spawning alone gave %+v", changes)
+	}
+	b.Spawn("bob", Unit{ID: 1, Rank: RankInfantry, Location: "d"})
+
+	// Moving in alone takes b, and a stays alice's after she leaves
+	checked, err := b.CheckMove(m, ArmyMove{Player: Player{Username: "alice"}, Units: []Unit{{ID: 1}}, ToLocation: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Move(checked)
+	if b.Owner("a") != "alice" || b.Owner("b") != "alice" {
+		t.Fatalf("a held by %q, b by %q", b.Owner("a"), b.Owner("b"))
+	}
+
+	// Two armies in one place leave it with its owner
+	b.Spawn("bob", Unit{ID: 2, Rank: RankInfantry, Location: "c"})
+	checked, err = b.CheckMove(m, ArmyMove{Player: Player{Username: "bob"}, Units: []Unit{{ID: 2}}, ToLocation: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes := b.Move(checked); len(changes) != 0 || b.Owner("b") != "alice" {
+		t.Fatalf("contested move gave %+v, b held by %q", changes, b.Owner("b"))
+	}
+
+	// The victor of a war there takes it
+	report := WarReport{
+		Location: "b",
+		Attacker: "bob",
+		Fates: []UnitFate{
+			{Owner: "bob", Unit: Unit{ID: 2, Rank: RankInfantry, Location: "b", HP: 1}},
+			{Owner: "alice", Unit: Unit{ID: 1, Rank: RankInfantry, Location: "b"}, Killed: true},
+		},
+		Winners: []string{"bob"},
+	}
+	changes := b.ApplyWarReport(report)
+	want := []ControlChange{{Location: "b", Owner: "bob", Previous: "alice", Reason: ControlConquered}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("war gave %+v, want %+v", changes, want)
+	}
+	if len(b.Army("alice").Units) != 0 || b.Army("bob").Units[2].HP != 1 {
+		t.Fatalf("after the war alice has %+v, bob %+v", b.Army("alice").Units, b.Army("bob").Units)
+	}
+	holdings := b.Holdings()
+	if !holdings["alice"]["a"] || !holdings["bob"]["b"] || !holdings["bob"]["c"] || !holdings["bob"]["d"] || len(holdings["bob"]) != 3 {
+		t.Fatalf("holdings %+v", holdings)
+	}
+}
+
+func TestDeclareWars(t *testing.T) {
+	b := NewBoard()
+	b.Spawn("alice", Unit{ID: 1, Rank: RankInfantry, Location: "a"})
+	b.Spawn("bob", Unit{ID: 1, Rank: RankInfantry, Location: "a"})
+	b.Spawn("carol", Unit{ID: 1, Rank: RankInfantry, Location: "a"})
+	b.Spawn("carol", Unit{ID: 2, Rank: RankInfantry, Location: "b"})
+
+	wars := b.DeclareWars("carol", time.Now().Add(time.Minute))
+	if len(wars) != 1 {
+		t.Fatalf("declared %d wars, want 1", len(wars))
+	}
+	rw := wars[0]
+	if rw.Attacker.Username != "carol" || rw.Defender.Username != "alice" || len(rw.Others) != 1 || rw.Others[0].Username != "bob" || rw.Location != "a" {
+		t.Fatalf("declared %+v, want carol attacking alice and bob in a", rw)
+	}
+	if len(rw.Attacker.Units) != 2 {
+		t.Fatalf("attacker carries %d units, want its whole army", len(rw.Attacker.Units))
+	}
+
+	// Nobody else declares a war in a until its result is in
+	if wars := b.DeclareWars("bob", time.Now().Add(time.Minute)); len(wars) != 0 {
+		t.Fatalf("declared %+v while a war was pending", wars)
+	}
+	if _, ok := b.PendingWar("bob", "a"); ok {
+		t.Fatal("bob has carol's war")
+	}
+	report, err := ResolveWar(rw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ApplyWarReport(report)
+	if _, ok := b.PendingWar("carol", "a"); ok {
+		t.Fatal("the war is still pending after its result")
+	}
+}
+
+func TestWarsExpire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "board.json")
+	b := NewBoard()
+	b.Spawn("alice", Unit{ID: 1, Rank: RankInfantry, Location: "a"})
+	b.Spawn("bob", Unit{ID: 1, Rank: RankInfantry, Location: "a"})
+
+	// bob never reports the war he was sent
+	if wars := b.DeclareWars("bob", time.Now().Add(-time.Second)); len(wars) != 1 {
+		t.Fatalf("declared %d wars, want 1", len(wars))
+	}
+	if _, ok := b.PendingWar("bob", "a"); ok {
+		t.Fatal("the war is still pending after its deadline")
+	}
+	wars := b.DeclareWars("alice", time.Now().Add(time.Minute))
+	if len(wars) != 1 || wars[0].Location != "a" {
+		t.Fatalf("declared %+v, want a war in a once bob's expired", wars)
+	}
+
+	// The deadline is saved with the war
+	if err := b.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadBoard(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loaded.PendingWar("alice", "a"); !ok {
+		t.Fatal("the loaded board lost alice's war")
+	}
+}
+
+func TestBoardSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "board.json")
+	b := NewBoard()
+	b.Spawn("alice", Unit{ID: 1, Rank: RankInfantry, Location: "a"})
+	if err := b.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadBoard(path)
+	if err != nil {
+		t.Fatalf("LoadBoard: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Army("alice"), b.Army("alice")) || loaded.Owner("a") != "alice" {
+		t.Fatalf("loaded %+v with a held by %q", loaded.Army("alice"), loaded.Owner("a"))
+	}
+	if fresh, err := LoadBoard(filepath.Join(t.TempDir(), "missing.json")); err != nil || len(fresh.Holdings()) != 0 {
+		t.Fatalf("missing board gave %v", err)
+	}
+}
+
+func TestCanSpawn(t *testing.T) {
+	held := map[Location]bool{"a": true}
+	tests := []struct {
+		name      string
+		first     bool
+		resources int
+		unit      Unit
+		wantErr   bool
+	}{
+		{"held territory", false, 10, Unit{Rank: RankInfantry, Location: "a"}, false},
+		{"territory not held", false, 10, Unit{Rank: RankInfantry, Location: "b"}, true},
+		{"first unit anywhere", true, 10, Unit{Rank: RankInfantry, Location: "b"}, false},
+		{"too dear", false, 7, Unit{Rank: RankArtillery, Location: "a"}, true},
+		{"bad rank", false, 10, Unit{Rank: "dragon", Location: "a"}, true},
+	}
+	for _, tt := range tests {
+		err := CanSpawn(held, tt.first, tt.resources, tt.unit)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,430 @@
+package gamelogic
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+)
+
+// MaxWarRounds caps how long a war lasts. Both sides may survive it.
+var MaxWarRounds = 3
+
+const (
+	// CombatRounds has each side deal its summed UnitPower every round
+	CombatRounds = "rounds"
+	// CombatDice rolls Risk style dice with rank modifiers
+	CombatDice = "dice"
+)
+
+// CombatMode is the resolver the server asks for when it declares war.
+// Whoever resolves the war uses the mode and seed carried in the message.
+var CombatMode = CombatRounds
+
+// WarCoalition makes the defenders in wars the server declares fight
+// together against the attacker. Otherwise every player fights for itself.
+var WarCoalition = false
+
+// Side is one player's units in a war. Sides on the same Team fight
+// together and never damage each other.
+type Side struct {
+	Owner string
+	Team  string
+	Units []Unit
+}
+
+// CombatResolver decides what a war does to the units involved. Given the
+// same sides, seed and rules it must always return the same report, so a
+// war can be resolved again after a failure, or replayed.
+type CombatResolver interface {
+	Name() string
+	// Resolve fights a war between sides. The first side is the attacker.
+	Resolve(sides []Side, loc Location, seed int64, rules Rules) WarReport
+}
+
+// Rules are the numbers a war is fought with. They travel with the war, so
+// the result does not depend on how the player resolving it is configured.
+type Rules struct {
+	UnitPower  map[UnitRank]int
+	UnitHealth map[UnitRank]int
+	MaxRounds  int
+}
+
+// CurrentRules copies this player's UnitPower, UnitHealth and MaxWarRounds
+func CurrentRules() Rules {
+	return Rules{
+		UnitPower:  maps.Clone(UnitPower),
+		UnitHealth: maps.Clone(UnitHealth),
+		MaxRounds:  MaxWarRounds,
+	}
+}
+
+// orCurrent is r, or this player's rules if r is empty because it came
+// from a client that predates them
+func (r Rules) orCurrent() Rules {
+	if r.UnitPower == nil {
+		return CurrentRules()
+	}
+	return r
+}
+
+func (r Rules) power(rank UnitRank) int {
+	return r.UnitPower[rank]
+}
+
+func (r Rules) maxHealth(rank UnitRank) int {
+	if hp, ok := r.UnitHealth[rank]; ok && hp > 0 {
+		return hp
+	}
+	return 1
+}
+
+// health is u's current hit points under these rules
+func (r Rules) health(u Unit) int {
+	if u.HP <= 0 {
+		return r.maxHealth(u.Rank)
+	}
+	return u.HP
+}
+
+// CombatResolverByName returns the resolver for a combat mode. An empty
+// name is the rounds mode, as sent by clients that predate the others.
+func CombatResolverByName(name string) (CombatResolver, error) {
+	switch name {
+	case "", CombatRounds:
+		return RoundsResolver{}, nil
+	case CombatDice:
+		return DiceResolver{}, nil
+	}
+	return nil, fmt.Errorf("unknown combat mode %q", name)
+}
+
+// UnitHealth is the hit points a unit of each rank starts with. UnitPower
+// is how much damage it deals each round.
+var UnitHealth = map[UnitRank]int{
+	RankArtillery: 20,
+	RankCavalry:   15,
+	RankInfantry:  10,
+}
+
+// MaxHealth is the hit points a new unit of rank has
+func MaxHealth(rank UnitRank) int {
+	return Rules{UnitHealth: UnitHealth}.maxHealth(rank)
+}
+
+// health is the unit's current hit points. Units from saves and messages
+// that predate hit points have none recorded and are at full health.
+func (u Unit) health() int {
+	return Rules{UnitHealth: UnitHealth}.health(u)
+}
+
+// UnitFate is what a war did to one unit. Unit holds its state afterwards.
+type UnitFate struct {
+	Owner    string
+	Unit     Unit
+	HPBefore int
+	Killed   bool
+}
+
+func (f UnitFate) String() string {
+	if f.Killed {
+		return fmt.Sprintf("%s's %s #%d: killed (%d HP lost)", f.Owner, f.Unit.Rank, f.Unit.ID, f.HPBefore)
+	}
+	if f.Unit.HP == f.HPBefore {
+		return fmt.Sprintf("%s's %s #%d: unharmed (%d HP)", f.Owner, f.Unit.Rank, f.Unit.ID, f.Unit.HP)
+	}
+	return fmt.Sprintf("%s's %s #%d: %d -> %d HP", f.Owner, f.Unit.Rank, f.Unit.ID, f.HPBefore, f.Unit.HP)
+}
+
+// WarReport describes how a war was fought
+type WarReport struct {
+	Location  Location
+	Attacker  string
+	Defenders []string
+	// Teams maps every participant to the team it fought for
+	Teams  map[string]string
+	Rounds int
+	// Fates lists each side's units by ID, the attacker's first
+	Fates []UnitFate
+	// Winners is set once the war is over, and is empty on a draw
+	Winners []string
+	Draw    bool
+	// Rules the war was fought with
+	Rules Rules
+}
+
+// Participants lists the attacker, then the defenders
+func (r WarReport) Participants() []string {
+	return append([]string{r.Attacker}, r.Defenders...)
+}
+
+// Outcome is how the war went for one participant
+func (r WarReport) Outcome(username string) WarOutcome {
+	if r.Draw {
+		return WarOutcomeDraw
+	}
+	for _, w := range r.Winners {
+		if w == username {
+			return WarOutcomeYouWon
+		}
+	}
+	return WarOutcomeOpponentWon
+}
+
+// Losers lists the participants that did not win or draw
+func (r WarReport) Losers() []string {
+	losers := []string{}
+	for _, p := range r.Participants() {
+		if r.Outcome(p) == WarOutcomeOpponentWon {
+			losers = append(losers, p)
+		}
+	}
+	return losers
+}
+
+// Losses counts owner's units in the war and how many of them were killed
+func (r WarReport) Losses(owner string) (killed, fought int) {
+	for _, f := range r.Fates {
+		if f.Owner != owner {
+			continue
+		}
+		fought++
+		if f.Killed {
+			killed++
+		}
+	}
+	return killed, fought
+}
+
+// Summary describes the outcome and casualties in one line, for the game log
+func (r WarReport) Summary() string {
+	casualties := []string{}
+	for i, p := range r.Participants() {
+		killed, fought := r.Losses(p)
+		if i == 0 {
+			casualties = append(casualties, fmt.Sprintf("%s lost %d of %d units", p, killed, fought))
+		} else {
+			casualties = append(casualties, fmt.Sprintf("%s lost %d of %d", p, killed, fought))
+		}
+	}
+	if r.Draw {
+		return fmt.Sprintf("A war between %s in %s resulted in a draw (%s)", joinNames(r.Participants()), r.Location, strings.Join(casualties, ", "))
+	}
+	return fmt.Sprintf("%s won a war against %s in %s (%s)", joinNames(r.Winners), joinNames(r.Losers()), r.Location, strings.Join(casualties, ", "))
+}
+
+// Survivors counts owner's units still alive after the war
+func (r WarReport) Survivors(owner string) int {
+	alive := 0
+	for _, f := range r.Fates {
+		if f.Owner == owner && !f.Killed {
+			alive++
+		}
+	}
+	return alive
+}
+
+// strength is the damage a team's survivors would deal in another round
+func (r WarReport) strength(team string) int {
+	rules := r.Rules.orCurrent()
+	power := 0
+	for _, f := range r.Fates {
+		if r.Teams[f.Owner] == team && !f.Killed {
+			power += rules.power(f.Unit.Rank)
+		}
+	}
+	return power
+}
+
+// decide picks the winning team: the only one with survivors, or the one
+// whose survivors are strongest. No survivors or a tie is a draw.
+func (r *WarReport) decide() {
+	best, bestPower, tied := "", -1, false
+	for _, team := range r.teams() {
+		power := r.strength(team)
+		switch {
+		case power > bestPower:
+			best, bestPower, tied = team, power, false
+		case power == bestPower:
+			tied = true
+		}
+	}
+
+	r.Winners = nil
+	r.Draw = bestPower <= 0 || tied
+	if r.Draw {
+		return
+	}
+	r.Winners = r.members(best)
+}
+
+// teams lists each team once, in the order its first member is listed
+func (r WarReport) teams() []string {
+	seen := map[string]bool{}
+	teams := []string{}
+	for _, p := range r.Participants() {
+		if team := r.Teams[p]; !seen[team] {
+			seen[team] = true
+			teams = append(teams, team)
+		}
+	}
+	return teams
+}
+
+// members lists the participants on team
+func (r WarReport) members(team string) []string {
+	members := []string{}
+	for _, p := range r.Participants() {
+		if r.Teams[p] == team {
+			members = append(members, p)
+		}
+	}
+	return members
+}
+
+// fighter is one unit as a war goes on
+type fighter struct {
+	side int
+	team string
+	unit Unit
+	hp   int
+}
+
+// battle holds every unit in a war, by side and then ID
+type battle struct {
+	sides    []Side
+	fighters []*fighter
+	rules    Rules
+}
+
+func newBattle(sides []Side, rules Rules) *battle {
+	b := &battle{sides: sides, rules: rules}
+	for s, side := range sides {
+		for _, u := range sortUnits(side.Units) {
+			b.fighters = append(b.fighters, &fighter{side: s, team: side.Team, unit: u, hp: rules.health(u)})
+		}
+	}
+	return b
+}
+
+// living lists the units still standing, optionally only those on team or
+// only those not on it
+func (b *battle) living(team string, onTeam bool) []*fighter {
+	living := []*fighter{}
+	for _, f := range b.fighters {
+		if f.hp > 0 && (team == "" || (f.team == team) == onTeam) {
+			living = append(living, f)
+		}
+	}
+	return living
+}
+
+// livingTeams lists the teams with units standing, in side order
+func (b *battle) livingTeams() []string {
+	seen := map[string]bool{}
+	teams := []string{}
+	for _, f := range b.living("", true) {
+		if !seen[f.team] {
+			seen[f.team] = true
+			teams = append(teams, f.team)
+		}
+	}
+	return teams
+}
+
+// report turns the health left after a war into a decided report
+func (b *battle) report(loc Location, rounds int) WarReport {
+	r := WarReport{Location: loc, Rounds: rounds, Teams: map[string]string{}, Rules: b.rules}
+	for s, side := range b.sides {
+		if s == 0 {
+			r.Attacker = side.Owner
+		} else {
+			r.Defenders = append(r.Defenders, side.Owner)
+		}
+		r.Teams[side.Owner] = side.Team
+	}
+	for _, f := range b.fighters {
+		fate := UnitFate{Owner: b.sides[f.side].Owner, Unit: f.unit, HPBefore: b.rules.health(f.unit)}
+		fate.Unit.HP = max(f.hp, 0)
+		fate.Killed = f.hp <= 0
+		r.Fates = append(r.Fates, fate)
+	}
+	r.decide()
+	return r
+}
+
+// RoundsResolver has every team deal its summed unit power to all the units
+// of the other teams at once, each round, until one team is left or the
+// rules' MaxRounds are up. It uses no randomness.
+type RoundsResolver struct{}
+
+func (RoundsResolver) Name() string { return CombatRounds }
+
+func (RoundsResolver) Resolve(sides []Side, loc Location, seed int64, rules Rules) WarReport {
+	b := newBattle(sides, rules)
+	rounds := 0
+	for rounds < rules.MaxRounds && len(b.livingTeams()) > 1 {
+		rounds++
+		// Pick everyone's damage and targets before anyone is hurt
+		teams := b.livingTeams()
+		damage := make([]int, len(teams))
+		targets := make([][]*fighter, len(teams))
+		for i, team := range teams {
+			damage[i] = power(b.living(team, true), rules)
+			targets[i] = b.living(team, false)
+		}
+		for i := range teams {
+			applyDamage(targets[i], damage[i], rules)
+		}
+	}
+	return b.report(loc, rounds)
+}
+
+// applyDamage spreads damage over targets in proportion to their maximum
+// health, so bigger units soak up more of it. What rounding leaves over
+// goes to the first targets.
+func applyDamage(targets []*fighter, damage int, rules Rules) {
+	total := 0
+	for _, f := range targets {
+		total += rules.maxHealth(f.unit.Rank)
+	}
+	if total == 0 || damage <= 0 {
+		return
+	}
+
+	dealt := 0
+	shares := make([]int, len(targets))
+	for i, f := range targets {
+		shares[i] = damage * rules.maxHealth(f.unit.Rank) / total
+		dealt += shares[i]
+	}
+	for i := 0; dealt < damage; i = (i + 1) % len(targets) {
+		shares[i]++
+		dealt++
+	}
+	for i, f := range targets {
+		f.hp -= shares[i]
+	}
+}
+
+func power(fighters []*fighter, rules Rules) int {
+	total := 0
+	for _, f := range fighters {
+		total += rules.power(f.unit.Rank)
+	}
+	return total
+}
+
+func sortUnits(units []Unit) []Unit {
+	sorted := append([]Unit(nil), units...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+// joinNames lists names as "a", "a and b" or "a, b and c"
+func joinNames(names []string) string {
+	if len(names) <= 1 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
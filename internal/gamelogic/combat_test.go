@@ -0,0 +1,93 @@
+package gamelogic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testWar(combat string) RecognitionOfWar {
+	units := func(ranks ...UnitRank) map[int]Unit {
+		m := map[int]Unit{}
+		for i, r := range ranks {
+			m[i+1] = Unit{ID: i + 1, Rank: r, Location: "europe", HP: MaxHealth(r)}
+		}
+		return m
+	}
+	return RecognitionOfWar{
+		Attacker: Player{Username: "alice", Units: units(RankArtillery, RankCavalry, RankInfantry, RankInfantry)},
+		Defender: Player{Username: "bob", Units: units(RankCavalry, RankCavalry, RankInfantry)},
+		Others:   []Player{{Username: "carol", Units: units(RankArtillery)}},
+		Location: "europe",
+		Combat:   combat,
+		Seed:     42,
+		Rules:    CurrentRules(),
+	}
+}
+
+// resolve has a fresh copy of the attacker resolve rw
+func resolve(t *testing.T, rw RecognitionOfWar) WarReport {
+	t.Helper()
+	outcome, report := NewGameState(rw.Attacker.Username).HandleWar(rw)
+	if outcome == WarOutcomeNotInvolved || outcome == WarOutcomeNoUnits {
+		t.Fatalf("war was not fought: outcome %v", outcome)
+	}
+	return report
+}
+
+func TestResolvingAWarTwiceAgrees(t *testing.T) {
+	for _, combat := range []string{CombatRounds, CombatDice} {
+		t.Run(combat, func(t *testing.T) {
+			rw := testWar(combat)
+			first := resolve(t, rw)
+			if again := resolve(t, rw); !reflect.DeepEqual(first, again) {
+				t.Fatalf("resolving again gave\n%+v\nwant\n%+v", again, first)
+			}
+
+			// A resolver configured differently still fights by the rules
+			// in the message
+			power, rounds := UnitPower[RankInfantry], MaxWarRounds
+			UnitPower[RankInfantry], MaxWarRounds = 50, 1
+			defer func() { UnitPower[RankInfantry], MaxWarRounds = power, rounds }()
+			if other := resolve(t, rw); !reflect.DeepEqual(first, other) {
+				t.Fatalf("a differently configured resolver gave\n%+v\nwant\n%+v", other, first)
+			}
+		})
+	}
+}
+
+func TestWarsWithoutRulesUseCurrentOnes(t *testing.T) {
+	rw := testWar(CombatRounds)
+	rw.Rules = Rules{}
+	report := resolve(t, rw)
+	if report.Rounds == 0 || report.Rules.MaxRounds != MaxWarRounds {
+		t.Fatalf("war without rules fought %d rounds with %+v", report.Rounds, report.Rules)
+	}
+}
+
+func TestApplyDamageSpreadsByHealth(t *testing.T) {
+	rules := Rules{UnitHealth: map[UnitRank]int{RankArtillery: 20, RankInfantry: 10}}
+	tests := []struct {
+		name   string
+		ranks  []UnitRank
+		damage int
+		want   []int
+	}{
+		{"by max health", []UnitRank{RankArtillery, RankInfantry}, 9, []int{14, 7}},
+		{"remainder to the first", []UnitRank{RankInfantry, RankInfantry, RankInfantry}, 4, []int{8, 9, 9}},
+		{"no damage", []UnitRank{RankInfantry}, 0, []int{10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets := []*fighter{}
+			for _, r := range tt.ranks {
+				targets = append(targets, &fighter{unit: Unit{Rank: r}, hp: rules.maxHealth(r)})
+			}
+			applyDamage(targets, tt.damage, rules)
+			for i, f := range targets {
+				if f.hp != tt.want[i] {
+					t.Fatalf("target %d has %d HP, want %d", i, f.hp, tt.want[i])
+				}
+			}
+		})
+	}
+}
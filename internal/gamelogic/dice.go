@@ -0,0 +1,101 @@
+package gamelogic
+
+import (
+	"math/rand"
+	"sort"
+)
+
+const (
+	// AttackDice and DefenseDice are how many dice each side rolls a round
+	AttackDice  = 3
+	DefenseDice = 2
+)
+
+// DiceModifier is added to every die a unit of each rank rolls
+var DiceModifier = map[UnitRank]int{
+	RankArtillery: 2,
+	RankCavalry:   1,
+	RankInfantry:  0,
+}
+
+// DiceResolver fights Risk style. Each round the attacking team takes on
+// every defending team in turn. Its strongest living units roll up to
+// AttackDice six sided dice, one each, and the defenders' roll up to
+// DefenseDice. Each die gets its unit's DiceModifier. The highest rolls are
+// paired off, ties going to the defender, and every pair the loser drops
+// costs its unit the winner's unit power in hit points. Defending teams do
+// not fight each other.
+type DiceResolver struct{}
+
+func (DiceResolver) Name() string { return CombatDice }
+
+// roll is one die and the unit that threw it
+type roll struct {
+	fighter *fighter
+	value   int
+}
+
+func (DiceResolver) Resolve(sides []Side, loc Location, seed int64, rules Rules) WarReport {
+	b := newBattle(sides, rules)
+	rng := rand.New(rand.NewSource(seed))
+	attackers := sides[0].Team
+
+	rounds := 0
+	for rounds < rules.MaxRounds && len(b.livingTeams()) > 1 {
+		rounds++
+		for _, team := range b.livingTeams() {
+			if team == attackers {
+				continue
+			}
+			attack := rollDice(b.living(attackers, true), AttackDice, rules, rng)
+			defense := rollDice(b.living(team, true), DefenseDice, rules, rng)
+			for i := 0; i < len(attack) && i < len(defense); i++ {
+				a, d := attack[i], defense[i]
+				if a.value > d.value {
+					d.fighter.hp -= rules.power(a.fighter.unit.Rank)
+				} else {
+					a.fighter.hp -= rules.power(d.fighter.unit.Rank)
+				}
+			}
+		}
+		// Attacker wiped out, nobody left to roll
+		if len(b.living(attackers, true)) == 0 {
+			break
+		}
+	}
+
+	report := b.report(loc, rounds)
+	// Defenders never fought each other, so beating the attacker is a win
+	// for all of them that are left
+	if len(b.living(attackers, true)) == 0 {
+		report.Winners = nil
+		for _, d := range report.Defenders {
+			if len(b.living(report.Teams[d], true)) > 0 {
+				report.Winners = append(report.Winners, d)
+			}
+		}
+		report.Draw = len(report.Winners) == 0
+	}
+	return report
+}
+
+// rollDice has up to dice of the strongest units roll once each and returns
+// the rolls highest first
+func rollDice(units []*fighter, dice int, rules Rules, rng *rand.Rand) []roll {
+	// Strongest first, keeping battle order on ties, so the same units
+	// always roll
+	units = append([]*fighter(nil), units...)
+	sort.SliceStable(units, func(i, j int) bool {
+		return rules.power(units[i].unit.Rank) > rules.power(units[j].unit.Rank)
+	})
+	if len(units) > dice {
+		units = units[:dice]
+	}
+
+	rolls := []roll{}
+	for _, f := range units {
+		rolls = append(rolls, roll{fighter: f, value: rng.Intn(6) + 1 + DiceModifier[f.unit.Rank]})
+	}
+	sort.SliceStable(rolls, func(i, j int) bool { return rolls[i].value > rolls[j].value })
+	return rolls
+}
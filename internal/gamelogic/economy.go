@@ -0,0 +1,226 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// EconomyEnabled makes spawning cost resources, paid for out of income
+// from held territories. Every server and player must agree on it.
+var EconomyEnabled = false
+
+// UnitCost is what spawning a unit of each rank costs
+var UnitCost = map[UnitRank]int{
+	RankInfantry:  1,
+	RankCavalry:   3,
+	RankArtillery: 8,
+}
+
+var (
+	// StartingResources is what a player has before their first income
+	StartingResources = 10
+	// BaseIncome is paid every turn or tick even with no territory
+	BaseIncome = 2
+	// TerritoryIncome is paid for each territory held
+	TerritoryIncome = 1
+)
+
+// Balance is a player's purse
+type Balance struct {
+	Resources   int
+	Income      int
+	Territories int
+}
+
+// EconomyUpdate is broadcast by the server whenever balances change
+type EconomyUpdate struct {
+	Balances map[string]Balance
+}
+
+// SpawnRequest asks the server to pay for a unit before it is spawned
+type SpawnRequest struct {
+	Username string
+	Token    string
+	Unit     Unit
+}
+
+type SpawnResponse struct {
+	Error   string
+	Balance Balance
+}
+
+// ArmyRequest asks the server for the player's army as its board has it
+type ArmyRequest struct {
+	Username string
+	Token    string
+}
+
+type ArmyResponse struct {
+	Error string
+	Army  Player
+}
+
+// Income is what holding held on m earns each turn or tick: the base
+// income, a share per territory and the bonus of every region held whole
+func Income(m *Map, held map[Location]bool) int {
+	return BaseIncome + TerritoryIncome*len(held) + m.Bonus(held)
+}
+
+// CanSpawn reports why a player holding held, with resources to spend, may
+// not spawn unit. A player's first unit may go wherever the board allows.
+// Every later one must go in a territory the player controls.
+func CanSpawn(held map[Location]bool, first bool, resources int, unit Unit) error {
+	cost, ok := UnitCost[unit.Rank]
+	if !ok {
+		return fmt.Errorf("%s is not a valid unit", unit.Rank)
+	}
+	if cost > resources {
+		return fmt.Errorf("a %s costs %d, you have %d", unit.Rank, cost, resources)
+	}
+	if !first && !held[unit.Location] {
+		return fmt.Errorf("you do not control %s", unit.Location)
+	}
+	return nil
+}
+
+// Balance is this player's purse as last heard from the server
+func (gs *GameState) Balance() Balance {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.balance
+}
+
+func (gs *GameState) SetBalance(b Balance) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.balance = b
+}
+
+// HandleEconomy picks this player's balance out of an update
+func (gs *GameState) HandleEconomy(update EconomyUpdate) {
+	if b, ok := update.Balances[gs.GetUsername()]; ok {
+		gs.SetBalance(b)
+	}
+}
+
+// Economy is the server's ledger of every player's resources. Armies and
+// territory come from the server's Board, and the map is an argument since
+// the server can switch maps.
+type Economy struct {
+	mu        *sync.Mutex
+	resources map[string]int
+}
+
+func NewEconomy() *Economy {
+	return &Economy{
+		mu:        &sync.Mutex{},
+		resources: map[string]int{},
+	}
+}
+
+// join gives a player seen for the first time their starting resources
+func (e *Economy) join(username string) {
+	if _, ok := e.resources[username]; !ok {
+		e.resources[username] = StartingResources
+	}
+}
+
+// Spawn charges username, who controls held, for unit, or says why it may
+// not be spawned. first is whether it is the player's only unit.
+func (e *Economy) Spawn(m *Map, username string, unit Unit, held map[Location]bool, first bool) (Balance, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.join(username)
+	if err := CanSpawn(held, first, e.resources[username], unit); err != nil {
+		return e.balance(m, username, held), err
+	}
+	e.resources[username] -= UnitCost[unit.Rank]
+	return e.balance(m, username, held), nil
+}
+
+// PayIncome pays every player what the territory in holdings earns them
+func (e *Economy) PayIncome(m *Map, holdings map[string]map[Location]bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for username := range e.resources {
+		e.resources[username] += Income(m, holdings[username])
+	}
+}
+
+// Update is every player's balance, to broadcast
+func (e *Economy) Update(m *Map, holdings map[string]map[Location]bool) EconomyUpdate {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	update := EconomyUpdate{Balances: map[string]Balance{}}
+	for username := range e.resources {
+		update.Balances[username] = e.balance(m, username, holdings[username])
+	}
+	return update
+}
+
+func (e *Economy) balance(m *Map, username string, held map[Location]bool) Balance {
+	return Balance{
+		Resources:   e.resources[username],
+		Income:      Income(m, held),
+		Territories: len(held),
+	}
+}
+
+// savedEconomy is the file form of an Economy
+type savedEconomy struct {
+	Resources map[string]int `json:"resources"`
+}
+
+// Save writes the ledger to path through a temporary file, so a crash never
+// leaves a half written one behind
+func (e *Economy) Save(path string) error {
+	e.mu.Lock()
+	data, err := json.MarshalIndent(savedEconomy{Resources: e.resources}, "", "  ")
+	e.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("could not encode economy: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".economy-*")
+	if err != nil {
+		return fmt.Errorf("could not write economy: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write economy: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write economy: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not write economy: %w", err)
+	}
+	return nil
+}
+
+// LoadEconomy reads a ledger saved at path, or starts a new one if there is
+// none yet
+func LoadEconomy(path string) (*Economy, error) {
+	e := NewEconomy()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read economy: %w", err)
+	}
+
+	var saved savedEconomy
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("could not decode economy %s: %w", path, err)
+	}
+	for username, r := range saved.Resources {
+		e.resources[username] = r
+	}
+	return e, nil
+}
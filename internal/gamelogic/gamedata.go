@@ -1,5 +1,7 @@
 package gamelogic
 
+import "sort"
+
 type Player struct {
 	Username string
 	Units    map[int]Unit
@@ -17,6 +19,8 @@ type Unit struct {
 	ID       int
 	Rank     UnitRank
 	Location Location
+	// HP is the unit's remaining hit points. Zero means full health.
+	HP int
 }
 
 type ArmyMove struct {
@@ -27,7 +31,19 @@ type ArmyMove struct {
 
 type RecognitionOfWar struct {
 	Attacker Player
+	// Defender declared the war. Others are the other players it knew had
+	// units in Location, who defend it too.
 	Defender Player
+	Others   []Player
+	Location Location
+	// Coalition has the defenders fight as one team
+	Coalition bool
+	// Combat names the CombatResolver, Seed feeds its dice and Rules are
+	// the declarer's unit power, health and round limit, so every client
+	// that resolves the war gets the same result
+	Combat string
+	Seed   int64
+	Rules  Rules
 }
 
 type Location string
@@ -40,13 +56,12 @@ func getAllRanks() map[UnitRank]struct{} {
 	}
 }
 
-func getAllLocations() map[Location]struct{} {
-	return map[Location]struct{}{
-		"americas":   {},
-		"europe":     {},
-		"africa":     {},
-		"asia":       {},
-		"australia":  {},
-		"antarctica": {},
+// Ranks lists every unit rank in a stable order
+func Ranks() []UnitRank {
+	ranks := []UnitRank{}
+	for rank := range getAllRanks() {
+		ranks = append(ranks, rank)
 	}
+	sort.Slice(ranks, func(i, j int) bool { return ranks[i] < ranks[j] })
+	return ranks
 }
@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"strings"
+
+	"golang.org/x/term"
 )
 
 func PrintClientHelp() {
@@ -18,6 +21,7 @@ func PrintClientHelp() {
 	fmt.Println("    example:")
 	fmt.Println("    spawn europe infantry")
 	fmt.Println("* status")
+	fmt.Println("* map")
 	fmt.Println("* spam <n>")
 	fmt.Println("    example:")
 	fmt.Println("    spam 5")
@@ -38,24 +42,74 @@ func ClientWelcome() (string, error) {
 	return username, nil
 }
 
+// ClientLogin asks for a username and password
+func ClientLogin() (string, string, error) {
+	fmt.Println("Welcome to the Peril client!")
+	fmt.Println("Please enter your username:")
+	words := GetInput()
+	if len(words) == 0 {
+		return "", "", errors.New("you must enter a username. goodbye")
+	}
+	username := words[0]
+	fmt.Println("Please enter your password:")
+	password, err := readPassword()
+	if err != nil {
+		return "", "", fmt.Errorf("could not read password: %w", err)
+	}
+	if password == "" {
+		return "", "", errors.New("you must enter a password. goodbye")
+	}
+	return username, password, nil
+}
+
+// readPassword reads a whole line, spaces included, without echoing it when
+// stdin is a terminal
+func readPassword() (string, error) {
+	fmt.Print("> ")
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		password, err := term.ReadPassword(fd)
+		fmt.Println()
+		return string(password), err
+	}
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
 func PrintServerHelp() {
 	fmt.Println("Possible commands:")
 	fmt.Println("* pause")
 	fmt.Println("* resume")
+	fmt.Println("* adduser <username> <password>")
+	fmt.Println("* sessions")
+	fmt.Println("* kick <username>")
+	fmt.Println("* abuse")
+	fmt.Println("* map [name or file]")
+	fmt.Println("* turn")
+	fmt.Println("* logs [--user X] [--since T] [--grep text] [--limit n]")
+	fmt.Println("    example:")
+	fmt.Println("    logs --grep \"against alice\" --since today")
 	fmt.Println("* quit")
 	fmt.Println("* help")
 }
 
 func GetInput() []string {
+	return strings.Fields(GetInputLine())
+}
+
+// GetInputLine prompts for a line and returns it as typed, for commands
+// that take quoted arguments
+func GetInputLine() string {
 	fmt.Print("> ")
 	scanner := bufio.NewScanner(os.Stdin)
 	scanned := scanner.Scan()
 	if !scanned {
-		return nil
+		return ""
 	}
-	line := scanner.Text()
-	line = strings.TrimSpace(line)
-	return strings.Fields(line)
+	return strings.TrimSpace(scanner.Text())
 }
 
 func GetMaliciousLog() string {
@@ -77,7 +131,7 @@ func PrintQuit() {
 }
 
 func (gs *GameState) CommandStatus() {
-	if gs.isPaused() {
+	if gs.IsPaused() {
 		fmt.Println("The game is paused.")
 		return
 	} else {
@@ -86,7 +140,11 @@ func (gs *GameState) CommandStatus() {
 
 	p := gs.GetPlayerSnap()
 	fmt.Printf("You are %s, and you have %d units.\n", p.Username, len(p.Units))
+	if EconomyEnabled {
+		b := gs.Balance()
+		fmt.Printf("You have %d resources, earning %d from %d territories.\n", b.Resources, b.Income, b.Territories)
+	}
 	for _, unit := range p.Units {
-		fmt.Printf("* %v: %v, %v\n", unit.ID, unit.Location, unit.Rank)
+		fmt.Printf("* %v: %v, %v (%d/%d HP)\n", unit.ID, unit.Location, unit.Rank, unit.health(), MaxHealth(unit.Rank))
 	}
 }
@@ -1,13 +1,26 @@
 package gamelogic
 
-import (
-	"sync"
-)
+import "sync"
 
 type GameState struct {
 	Player Player
 	Paused bool
-	mu     *sync.RWMutex
+	// nextUnitID only ever goes up, so the ID of a unit lost in a war is
+	// never handed out again
+	nextUnitID int
+	world      *Map
+	mu         *sync.RWMutex
+	saveMu     *sync.Mutex
+	// revision counts snapshots, so the newer of two saves can be told apart
+	revision int
+	// others is the last snapshot seen of every other player
+	others map[string]Player
+	// control is who each territory belongs to, as announced
+	control map[Location]string
+	turn    TurnState
+	// ApproveSpawn, if set, must agree to every unit before it is spawned
+	ApproveSpawn func(Unit) error
+	balance      Balance
 }
 
 func NewGameState(username string) *GameState {
@@ -16,8 +29,13 @@ func NewGameState(username string) *GameState {
 			Username: username,
 			Units:    map[int]Unit{},
 		},
-		Paused: false,
-		mu:     &sync.RWMutex{},
+		Paused:     false,
+		nextUnitID: 1,
+		world:      DefaultMap(),
+		others:     map[string]Player{},
+		control:    map[Location]string{},
+		mu:         &sync.RWMutex{},
+		saveMu:     &sync.Mutex{},
 	}
 }
 
@@ -33,25 +51,64 @@ func (gs *GameState) pauseGame() {
 	gs.Paused = true
 }
 
-func (gs *GameState) isPaused() bool {
+func (gs *GameState) IsPaused() bool {
 	gs.mu.RLock()
 	defer gs.mu.RUnlock()
 	return gs.Paused
 }
 
+// nextUnit is a new unit with the next free ID, not yet added
+func (gs *GameState) nextUnit(rank UnitRank, loc Location) Unit {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return Unit{
+		ID:       gs.nextUnitID,
+		Rank:     rank,
+		Location: loc,
+		HP:       MaxHealth(rank),
+	}
+}
+
+// addUnit adds a unit from nextUnit and moves the ID counter past it
 func (gs *GameState) addUnit(u Unit) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
+	gs.nextUnitID = max(gs.nextUnitID, u.ID+1)
 	gs.Player.Units[u.ID] = u
 }
 
-func (gs *GameState) removeUnitsInLocation(loc Location) {
+// SeedUnits catches up with army, the player's army as the server's board
+// has it. Units the board has and this game does not, such as ones spawned
+// before a save was lost, are added, and new units get IDs past all of
+// them, so the board never refuses a spawn for reusing one.
+func (gs *GameState) SeedUnits(army Player) {
 	gs.mu.Lock()
 	defer gs.mu.Unlock()
-	for k, v := range gs.Player.Units {
-		if v.Location == loc {
-			delete(gs.Player.Units, k)
+	for id, u := range army.Units {
+		if _, ok := gs.Player.Units[id]; !ok {
+			gs.Player.Units[id] = u
+		}
+		gs.nextUnitID = max(gs.nextUnitID, id+1)
+	}
+}
+
+// applyWarReport removes this player's units killed in a war and updates
+// the health of those that survived it
+func (gs *GameState) applyWarReport(report WarReport) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	for _, fate := range report.Fates {
+		if fate.Owner != gs.Player.Username {
+			continue
+		}
+		if _, ok := gs.Player.Units[fate.Unit.ID]; !ok {
+			continue
+		}
+		if fate.Killed {
+			delete(gs.Player.Units, fate.Unit.ID)
+			continue
 		}
+		gs.Player.Units[fate.Unit.ID] = fate.Unit
 	}
 }
 
@@ -94,3 +151,53 @@ func (gs *GameState) GetPlayerSnap() Player {
 		Units:    Units,
 	}
 }
+
+// observePlayer remembers the latest snapshot of another player
+func (gs *GameState) observePlayer(p Player) {
+	if p.Username == "" || p.Username == gs.GetUsername() {
+		return
+	}
+	units := map[int]Unit{}
+	for k, v := range p.Units {
+		units[k] = v
+	}
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.others[p.Username] = Player{Username: p.Username, Units: units}
+}
+
+// observeWarReport brings the snapshots of other players up to date with
+// what a war did to their units. Players not seen before are learned from
+// their survivors.
+func (gs *GameState) observeWarReport(report WarReport) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	for _, fate := range report.Fates {
+		if fate.Owner == gs.Player.Username {
+			continue
+		}
+		other, ok := gs.others[fate.Owner]
+		if !ok {
+			other = Player{Username: fate.Owner, Units: map[int]Unit{}}
+			gs.others[fate.Owner] = other
+		}
+		if fate.Killed {
+			delete(other.Units, fate.Unit.ID)
+		} else {
+			other.Units[fate.Unit.ID] = fate.Unit
+		}
+	}
+}
+
+// Map is the map moves and spawns are checked against
+func (gs *GameState) Map() *Map {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.world
+}
+
+func (gs *GameState) SetMap(m *Map) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	gs.world = m
+}
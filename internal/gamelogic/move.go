@@ -3,6 +3,7 @@ package gamelogic
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 )
 
@@ -28,16 +29,35 @@ func (gs *GameState) HandleMove(move ArmyMove) MoveOutcome {
 	if player.Username == move.Player.Username {
 		return MoveOutcomeSamePlayer
 	}
+	gs.observePlayer(move.Player)
 
-	overlappingLocation := getOverlappingLocation(player, move.Player)
-	if overlappingLocation != "" {
-		fmt.Printf("You have units in %s! You are at war with %s!\n", overlappingLocation, move.Player.Username)
+	contested := getOverlappingLocations(player, move.Player)
+	if len(contested) > 0 {
+		for _, loc := range contested {
+			fmt.Printf("You have units in %s! You are at war with %s!\n", loc, move.Player.Username)
+		}
 		return MoveOutcomeMakeWar
 	}
 	fmt.Printf("You are safe from %s's units.\n", move.Player.Username)
 	return MoveOutComeSafe
 }
 
+// getOverlappingLocations lists every location both players have units in
+func getOverlappingLocations(p1 Player, p2 Player) []Location {
+	seen := map[Location]bool{}
+	locs := []Location{}
+	for _, u1 := range p1.Units {
+		for _, u2 := range p2.Units {
+			if u1.Location == u2.Location && !seen[u1.Location] {
+				seen[u1.Location] = true
+				locs = append(locs, u1.Location)
+			}
+		}
+	}
+	sort.Slice(locs, func(i, j int) bool { return locs[i] < locs[j] })
+	return locs
+}
+
 func getOverlappingLocation(p1 Player, p2 Player) Location {
 	for _, u1 := range p1.Units {
 		for _, u2 := range p2.Units {
@@ -50,15 +70,18 @@ func getOverlappingLocation(p1 Player, p2 Player) Location {
 }
 
 func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
-	if gs.isPaused() {
+	if gs.IsPaused() {
 		return ArmyMove{}, errors.New("the game is paused, you can not move units")
 	}
+	if err := gs.CanOrder(); err != nil {
+		return ArmyMove{}, err
+	}
 	if len(words) < 3 {
 		return ArmyMove{}, errors.New("usage: move <location> <unitID> <unitID> <unitID> etc")
 	}
 	newLocation := Location(words[1])
-	locations := getAllLocations()
-	if _, ok := locations[newLocation]; !ok {
+	world := gs.Map()
+	if !world.Has(newLocation) {
 		return ArmyMove{}, fmt.Errorf("error: %s is not a valid location", newLocation)
 	}
 	unitIDs := []int{}
@@ -71,17 +94,24 @@ func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
 		unitIDs = append(unitIDs, unitID)
 	}
 
+	// Check every unit before moving any, so a bad move changes nothing
 	newUnits := []Unit{}
 	for _, unitID := range unitIDs {
 		unit, ok := gs.GetUnit(unitID)
 		if !ok {
 			return ArmyMove{}, fmt.Errorf("error: unit with ID %v not found", unitID)
 		}
-		unit.Location = newLocation
-		gs.UpdateUnit(unit)
+		if err := world.CanMove(unit, newLocation); err != nil {
+			return ArmyMove{}, err
+		}
 		newUnits = append(newUnits, unit)
 	}
 
+	for i := range newUnits {
+		newUnits[i].Location = newLocation
+		gs.UpdateUnit(newUnits[i])
+	}
+
 	mv := ArmyMove{
 		ToLocation: newLocation,
 		Units:      newUnits,
@@ -90,3 +120,30 @@ func (gs *GameState) CommandMove(words []string) (ArmyMove, error) {
 	fmt.Printf("Moved %v units to %s\n", len(mv.Units), mv.ToLocation)
 	return mv, nil
 }
+
+// UnitsBefore picks the units move took out of before, a snapshot taken
+// ahead of it, for RollbackMove
+func UnitsBefore(before Player, move ArmyMove) []Unit {
+	previous := []Unit{}
+	for _, u := range move.Units {
+		if prev, ok := before.Units[u.ID]; ok {
+			previous = append(previous, prev)
+		}
+	}
+	return previous
+}
+
+// RollbackMove puts units moved by move back where they were before it.
+// Units that have since moved on or been lost are left alone.
+func (gs *GameState) RollbackMove(move ArmyMove, previous []Unit) {
+	gs.mu.Lock()
+	defer gs.mu.Unlock()
+	for _, prev := range previous {
+		current, ok := gs.Player.Units[prev.ID]
+		if !ok || current.Location != move.ToLocation {
+			continue
+		}
+		current.Location = prev.Location
+		gs.Player.Units[prev.ID] = current
+	}
+}
@@ -0,0 +1,135 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// savedGame is what Save writes. The pause state is left out because the
+// server broadcasts it.
+type savedGame struct {
+	Player     Player
+	NextUnitID int
+	// Control is who each territory belongs to, as last announced
+	Control map[Location]string
+	// Revision goes up with every snapshot
+	Revision int
+}
+
+// Snapshot encodes the game as Save writes it, for storing alongside other
+// data. Every snapshot has a higher Revision than the last.
+func (gs *GameState) Snapshot() ([]byte, error) {
+	gs.saveMu.Lock()
+	defer gs.saveMu.Unlock()
+	return gs.snapshot()
+}
+
+func (gs *GameState) snapshot() ([]byte, error) {
+	gs.revision++
+	gs.mu.RLock()
+	data, err := json.MarshalIndent(savedGame{
+		Player:     gs.Player,
+		NextUnitID: gs.nextUnitID,
+		Control:    gs.control,
+		Revision:   gs.revision,
+	}, "", "  ")
+	gs.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("could not encode game state: %w", err)
+	}
+	return data, nil
+}
+
+// Revision is the revision of the last snapshot taken or loaded
+func (gs *GameState) Revision() int {
+	gs.saveMu.Lock()
+	defer gs.saveMu.Unlock()
+	return gs.revision
+}
+
+// Save writes the player's units, ID allocator and known territory owners
+// to path atomically, so a crash mid-write leaves the previous save intact
+func (gs *GameState) Save(path string) error {
+	gs.saveMu.Lock()
+	defer gs.saveMu.Unlock()
+
+	data, err := gs.snapshot()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".state-*")
+	if err != nil {
+		return fmt.Errorf("could not write game state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write game state: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write game state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write game state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not write game state: %w", err)
+	}
+	return nil
+}
+
+// LoadGameState restores username's game from path, or starts a new one if
+// there is no save yet
+func LoadGameState(path, username string) (*GameState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewGameState(username), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read game state: %w", err)
+	}
+
+	gs, err := RestoreGameState(data, username)
+	if err != nil {
+		return nil, fmt.Errorf("game state %s: %w", path, err)
+	}
+	return gs, nil
+}
+
+// RestoreGameState restores username's game from a snapshot
+func RestoreGameState(data []byte, username string) (*GameState, error) {
+	gs := NewGameState(username)
+
+	var saved savedGame
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("could not decode game state: %w", err)
+	}
+	if saved.Player.Username != username {
+		return nil, fmt.Errorf("game state belongs to %s, not %s", saved.Player.Username, username)
+	}
+
+	if saved.Player.Units != nil {
+		gs.Player.Units = saved.Player.Units
+	}
+	gs.nextUnitID = saved.NextUnitID
+	gs.revision = saved.Revision
+	for loc, owner := range saved.Control {
+		gs.control[loc] = owner
+	}
+	// Never hand out an ID a saved unit already has, even if the counter
+	// was lost or edited
+	for id := range gs.Player.Units {
+		if id >= gs.nextUnitID {
+			gs.nextUnitID = id + 1
+		}
+	}
+	if gs.nextUnitID < 1 {
+		gs.nextUnitID = 1
+	}
+	return gs, nil
+}
@@ -0,0 +1,88 @@
+package gamelogic
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.alice.json")
+	gs := NewGameState("alice")
+	gs.addUnit(Unit{ID: 1, Rank: RankInfantry, Location: "europe"})
+	gs.control["europe"] = "alice"
+
+	if err := gs.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	loaded, err := LoadGameState(path, "alice")
+	if err != nil {
+		t.Fatalf("LoadGameState: %v", err)
+	}
+	if len(loaded.Player.Units) != 1 || loaded.Owner("europe") != "alice" {
+		t.Fatalf("loaded %+v with europe held by %q", loaded.Player, loaded.Owner("europe"))
+	}
+	if loaded.Revision() != gs.Revision() {
+		t.Fatalf("loaded revision %d, saved %d", loaded.Revision(), gs.Revision())
+	}
+
+	if _, err := LoadGameState(path, "bob"); err == nil {
+		t.Fatal("loaded alice's game as bob")
+	}
+	if fresh, err := LoadGameState(filepath.Join(t.TempDir(), "missing.json"), "alice"); err != nil || fresh.Revision() != 0 {
+		t.Fatalf("missing save gave %v, revision %d", err, fresh.Revision())
+	}
+}
+
+func TestSnapshotRevisions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.alice.json")
+	gs := NewGameState("alice")
+
+	tests := []struct {
+		name string
+		take func() ([]byte, error)
+	}{
+		{"save", func() ([]byte, error) { return nil, gs.Save(path) }},
+		{"snapshot", gs.Snapshot},
+		{"save again", func() ([]byte, error) { return nil, gs.Save(path) }},
+		{"snapshot again", gs.Snapshot},
+	}
+	last := gs.Revision()
+	for _, tt := range tests {
+		data, err := tt.take()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if gs.Revision() <= last {
+			t.Fatalf("%s: revision %d did not go up from %d", tt.name, gs.Revision(), last)
+		}
+		last = gs.Revision()
+
+		if data == nil {
+			continue
+		}
+		restored, err := RestoreGameState(data, "alice")
+		if err != nil {
+			t.Fatalf("%s: RestoreGameState: %v", tt.name, err)
+		}
+		if restored.Revision() != last {
+			t.Fatalf("%s: restored revision %d, want %d", tt.name, restored.Revision(), last)
+		}
+	}
+}
+
+func TestSeedUnits(t *testing.T) {
+	// alice lost her save, but the board still has her units 1 and 3
+	gs := NewGameState("alice")
+	army := Player{Username: "alice", Units: map[int]Unit{
+		1: {ID: 1, Rank: RankInfantry, Location: "europe"},
+		3: {ID: 3, Rank: RankCavalry, Location: "asia"},
+	}}
+	gs.SeedUnits(army)
+
+	if len(gs.GetPlayerSnap().Units) != 2 {
+		t.Fatalf("seeded units %+v, want the board's two", gs.GetPlayerSnap().Units)
+	}
+	if id, err := gs.CommandSpawn([]string{"spawn", "europe", "infantry"}); err != nil || id != 4 {
+		t.Fatalf("spawned unit %d (%v), want 4", id, err)
+	}
+}
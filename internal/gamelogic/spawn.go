@@ -3,6 +3,7 @@ package gamelogic
 import (
 	"errors"
 	"fmt"
+	"slices"
 )
 
 func (gs *GameState) CommandSpawn(words []string) (int, error) {
@@ -11,10 +12,14 @@ func (gs *GameState) CommandSpawn(words []string) (int, error) {
 	}
 
 	locationName := words[1]
-	locations := getAllLocations()
-	if _, ok := locations[Location(locationName)]; !ok {
+	world := gs.Map()
+	if !world.Has(Location(locationName)) {
 		return 0, fmt.Errorf("error: %s is not a valid location", locationName)
 	}
+	starts := world.StartingPositions()
+	if len(starts) > 0 && len(gs.GetPlayerSnap().Units) == 0 && !slices.Contains(starts, Location(locationName)) {
+		return 0, fmt.Errorf("error: your first unit must start in one of %v", starts)
+	}
 
 	rank := words[2]
 	units := getAllRanks()
@@ -22,13 +27,14 @@ func (gs *GameState) CommandSpawn(words []string) (int, error) {
 		return 0, fmt.Errorf("error: %s is not a valid unit", rank)
 	}
 
-	id := len(gs.getUnitsSnap()) + 1
-	gs.addUnit(Unit{
-		ID:       id,
-		Rank:     UnitRank(rank),
-		Location: Location(locationName),
-	})
+	unit := gs.nextUnit(UnitRank(rank), Location(locationName))
+	if gs.ApproveSpawn != nil {
+		if err := gs.ApproveSpawn(unit); err != nil {
+			return 0, err
+		}
+	}
+	gs.addUnit(unit)
 
-	fmt.Printf("Spawned a(n) %s in %s with id %v\n", rank, locationName, id)
-	return id, nil
+	fmt.Printf("Spawned a(n) %s in %s with id %v\n", rank, locationName, unit.ID)
+	return unit.ID, nil
 }
@@ -0,0 +1,115 @@
+package gamelogic
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	// ControlOccupied means the owner's units are the only ones there
+	ControlOccupied = "occupied"
+	// ControlConquered means the owner won a war there
+	ControlConquered = "conquered"
+)
+
+// ControlChange is a territory changing hands. The server decides control
+// on its board and announces each change, and every player applies them.
+type ControlChange struct {
+	Location Location
+	Owner    string
+	// Previous is who controlled it before, or empty if nobody did
+	Previous string
+	// Reason is ControlOccupied or ControlConquered
+	Reason string
+}
+
+func (c ControlChange) String() string {
+	if c.Previous == "" {
+		return fmt.Sprintf("%s %s %s", c.Owner, c.Reason, c.Location)
+	}
+	return fmt.Sprintf("%s %s %s from %s", c.Owner, c.Reason, c.Location, c.Previous)
+}
+
+// Victor is the winner that takes control of the war's location: the one
+// with the most survivors, the first by name on a tie. There is none after
+// a draw, or if no winner has units left.
+func (r WarReport) Victor() (string, bool) {
+	best, bestAlive := "", 0
+	for _, w := range r.Winners {
+		alive := r.Survivors(w)
+		if alive > bestAlive || (alive == bestAlive && alive > 0 && w < best) {
+			best, bestAlive = w, alive
+		}
+	}
+	return best, bestAlive > 0
+}
+
+// Owner is who controls loc, or empty if nobody has yet
+func (gs *GameState) Owner(loc Location) string {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.control[loc]
+}
+
+// Held lists the territories this player controls, as announced
+func (gs *GameState) Held() []Location {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	held := []Location{}
+	for loc, owner := range gs.control {
+		if owner == gs.Player.Username {
+			held = append(held, loc)
+		}
+	}
+	sort.Slice(held, func(i, j int) bool { return held[i] < held[j] })
+	return held
+}
+
+// HandleControl applies a control change. It reports false if the change
+// was already known.
+func (gs *GameState) HandleControl(change ControlChange) bool {
+	gs.mu.Lock()
+	if gs.control[change.Location] == change.Owner {
+		gs.mu.Unlock()
+		return false
+	}
+	gs.control[change.Location] = change.Owner
+	gs.mu.Unlock()
+
+	fmt.Println()
+	if change.Owner == gs.GetUsername() {
+		fmt.Printf("You %s %s!\n", change.Reason, change.Location)
+	} else {
+		fmt.Printf("%s.\n", change)
+	}
+	return true
+}
+
+// CommandMap shows who controls each territory of the map and the units
+// last seen there
+func (gs *GameState) CommandMap() {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+
+	players := []Player{gs.Player}
+	for _, p := range gs.others {
+		players = append(players, p)
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].Username < players[j].Username })
+
+	fmt.Printf("==== %s ====\n", gs.world.Name)
+	for _, loc := range gs.world.Locations() {
+		line := fmt.Sprintf("* %s: ", loc)
+		if owner := gs.control[loc]; owner != "" {
+			line += fmt.Sprintf("held by %s", owner)
+		} else {
+			line += "unclaimed"
+		}
+		for _, p := range players {
+			if n := len(getUnitsAtLocation(p.Units, loc)); n > 0 {
+				line += fmt.Sprintf(", %s %d", p.Username, n)
+			}
+		}
+		fmt.Println(line)
+	}
+}
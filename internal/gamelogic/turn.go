@@ -0,0 +1,118 @@
+package gamelogic
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// TurnsOff lets players move whenever they like
+	TurnsOff = "off"
+	// TurnsSimultaneous has everyone give orders during the same turn
+	TurnsSimultaneous = "simultaneous"
+	// TurnsSequential gives each player a turn of their own in turn
+	TurnsSequential = "sequential"
+)
+
+// TurnMode is how moves are timed. Every server and player must agree on it.
+var TurnMode = TurnsOff
+
+// TurnState is broadcast by the server when a turn starts and when it ends
+type TurnState struct {
+	Number int
+	// Open is true while orders are accepted
+	Open bool
+	// Player is whose turn it is in sequential mode
+	Player string
+	EndsAt time.Time
+	// Moves are the orders given during the turn, sent when it ends. Each
+	// move carries its player's army as the server's board has it.
+	Moves []ArmyMove
+}
+
+// TurnOrder asks the server to carry out a move at the end of a turn
+type TurnOrder struct {
+	Username string
+	Token    string
+	Turn     int
+	Move     ArmyMove
+}
+
+type TurnOrderResponse struct {
+	Error string
+}
+
+// Turn is the last turn the server announced
+func (gs *GameState) Turn() TurnState {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.turn
+}
+
+// CanOrder reports why this player cannot move right now in turn mode
+func (gs *GameState) CanOrder() error {
+	if TurnMode == TurnsOff {
+		return nil
+	}
+	turn := gs.Turn()
+	if !turn.Open {
+		return errors.New("orders are closed, wait for the next turn")
+	}
+	if turn.Player != "" && turn.Player != gs.GetUsername() {
+		return fmt.Errorf("it is %s's turn", turn.Player)
+	}
+	return nil
+}
+
+// HandleTurn records a turn starting or ending. When one ends it applies
+// everyone else's orders at once. The server declares the wars they start.
+func (gs *GameState) HandleTurn(ts TurnState) {
+	defer fmt.Println("------------------------")
+	fmt.Println()
+
+	moves := ts.Moves
+	ts.Moves = nil
+	gs.mu.Lock()
+	gs.turn = ts
+	gs.mu.Unlock()
+
+	if ts.Open {
+		fmt.Printf("==== Turn %d ====\n", ts.Number)
+		if ts.Player != "" {
+			fmt.Printf("It is %s's turn.\n", ts.Player)
+		}
+		fmt.Printf("Orders close at %s.\n", ts.EndsAt.Format(time.TimeOnly))
+		return
+	}
+
+	fmt.Printf("==== Turn %d is over ====\n", ts.Number)
+	if len(moves) == 0 {
+		fmt.Println("No orders were given.")
+		return
+	}
+
+	// Only each player's final army matters for who ends up where
+	final := map[string]ArmyMove{}
+	moved := []string{}
+	for _, move := range moves {
+		fmt.Printf("%s moved %d unit(s) to %s\n", move.Player.Username, len(move.Units), move.ToLocation)
+		if _, ok := final[move.Player.Username]; !ok {
+			moved = append(moved, move.Player.Username)
+		}
+		final[move.Player.Username] = move
+	}
+	sort.Strings(moved)
+
+	me := gs.GetUsername()
+	for _, name := range moved {
+		if name == me {
+			continue
+		}
+		gs.observePlayer(final[name].Player)
+		for _, loc := range getOverlappingLocations(gs.GetPlayerSnap(), final[name].Player) {
+			fmt.Printf("You have units in %s! You are at war with %s!\n", loc, name)
+		}
+	}
+}
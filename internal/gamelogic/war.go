@@ -2,6 +2,8 @@ package gamelogic
 
 import (
 	"fmt"
+	"math/rand"
+	"slices"
 )
 
 type WarOutcome int
@@ -14,66 +16,154 @@ const (
 	WarOutcomeDraw
 )
 
-// UnitPower represents the power level of different unit types
+// UnitPower represents the power level of different unit types. It is the
+// damage a unit deals each round of a war.
 var UnitPower = map[UnitRank]int{
 	RankArtillery: 10,
 	RankCavalry:   5,
 	RankInfantry:  1,
 }
 
-func (gs *GameState) HandleWar(rw RecognitionOfWar) (outcome WarOutcome, winner string, loser string) {
+// NewWar declares war in loc with the configured combat mode, rules and
+// alliances and a fresh seed
+func NewWar(attacker, defender Player, others []Player, loc Location) RecognitionOfWar {
+	return RecognitionOfWar{
+		Attacker:  attacker,
+		Defender:  defender,
+		Others:    others,
+		Location:  loc,
+		Coalition: WarCoalition,
+		Combat:    CombatMode,
+		Seed:      rand.Int63(),
+		Rules:     CurrentRules(),
+	}
+}
+
+// HandleWar resolves a war this player is the attacker in and applies the
+// attacker's losses. The returned report must be sent to the defenders,
+// who apply their own losses with HandleWarResult.
+func (gs *GameState) HandleWar(rw RecognitionOfWar) (WarOutcome, WarReport) {
 	defer fmt.Println("------------------------")
 	fmt.Println()
 	fmt.Println("==== War Declared ====")
-	fmt.Printf("%s has declared war on %s!\n", rw.Attacker.Username, rw.Defender.Username)
+	defenders := append([]Player{rw.Defender}, rw.Others...)
+	names := []string{}
+	for _, d := range defenders {
+		names = append(names, d.Username)
+	}
+	fmt.Printf("%s is at war with %s in %s!\n", rw.Attacker.Username, joinNames(names), rw.Location)
 
 	player := gs.GetPlayerSnap()
 
 	// Validate war participants
 	if !isValidWarParticipant(player, rw) {
-		return WarOutcomeNotInvolved, "", ""
+		return WarOutcomeNotInvolved, WarReport{}
 	}
 
-	// Find overlapping location
-	overlappingLocation := getOverlappingLocation(rw.Attacker, rw.Defender)
-	if overlappingLocation == "" {
-		fmt.Printf("Error! No units are in the same location. No war will be fought.\n")
-		return WarOutcomeNoUnits, "", ""
+	resolver, err := CombatResolverByName(rw.Combat)
+	if err != nil {
+		fmt.Printf("Error! %v. No war will be fought.\n", err)
+		return WarOutcomeNoUnits, WarReport{}
 	}
 
-	// Get units at the overlapping location
-	attackerUnits := getUnitsAtLocation(rw.Attacker.Units, Location(overlappingLocation))
-	defenderUnits := getUnitsAtLocation(rw.Defender.Units, Location(overlappingLocation))
+	fmt.Printf("The war is fought with %s.\n", resolver.Name())
+
+	location, sides, ok := warSides(rw)
+	if !ok {
+		fmt.Printf("Error! No units are in the same location. No war will be fought.\n")
+		return WarOutcomeNoUnits, WarReport{}
+	}
 
 	// Display unit information
-	displayWarUnits(rw.Attacker.Username, attackerUnits)
-	displayWarUnits(rw.Defender.Username, defenderUnits)
+	rules := rw.Rules.orCurrent()
+	for _, side := range sides {
+		displayWarUnits(side.Owner, side.Units, rules)
+	}
+
+	// Fight it out. Only this player's own units are changed here.
+	report := resolver.Resolve(sides, location, rw.Seed, rules)
+	displayWarReport(report)
+	gs.applyWarReport(report)
+	gs.observeWarReport(report)
+
+	outcome := report.Outcome(player.Username)
+	displayWarOutcome(report, player.Username)
+	return outcome, report
+}
+
+// ResolveWar fights rw the way its attacker does in HandleWar, without
+// applying the result to anyone. The server uses it to settle the result
+// of a war it declared.
+func ResolveWar(rw RecognitionOfWar) (WarReport, error) {
+	resolver, err := CombatResolverByName(rw.Combat)
+	if err != nil {
+		return WarReport{}, err
+	}
+	location, sides, ok := warSides(rw)
+	if !ok {
+		return WarReport{}, fmt.Errorf("no units are in the same location")
+	}
+	return resolver.Resolve(sides, location, rw.Seed, rw.Rules.orCurrent()), nil
+}
 
-	// Calculate power levels
-	attackerPower := calculatePowerLevel(attackerUnits)
-	defenderPower := calculatePowerLevel(defenderUnits)
-	fmt.Printf("Attacker has a power level of %v\n", attackerPower)
-	fmt.Printf("Defender has a power level of %v\n", defenderPower)
+// warSides picks where rw is fought and each side's units there. Defenders
+// with none there, such as ones that have since moved away, sit the war
+// out. It reports false if there is nobody to fight.
+func warSides(rw RecognitionOfWar) (Location, []Side, bool) {
+	// Wars from older clients do not say where they are
+	location := rw.Location
+	if location == "" {
+		location = getOverlappingLocation(rw.Attacker, rw.Defender)
+	}
 
-	// Determine war outcome
-	outcome, winner, loser = determineWarOutcome(attackerPower, defenderPower, rw, player.Username)
+	sides := []Side{{
+		Owner: rw.Attacker.Username,
+		Team:  rw.Attacker.Username,
+		Units: getUnitsAtLocation(rw.Attacker.Units, location),
+	}}
+	for _, d := range append([]Player{rw.Defender}, rw.Others...) {
+		units := getUnitsAtLocation(d.Units, location)
+		if len(units) == 0 || d.Username == rw.Attacker.Username {
+			continue
+		}
+		team := d.Username
+		if rw.Coalition {
+			team = rw.Defender.Username
+		}
+		sides = append(sides, Side{Owner: d.Username, Team: team, Units: units})
+	}
+	if location == "" || len(sides[0].Units) == 0 || len(sides) < 2 {
+		return location, nil, false
+	}
+	return location, sides, true
+}
 
-	// Handle unit removal based on outcome
-	if outcome == WarOutcomeDraw || (outcome == WarOutcomeOpponentWon && player.Username == rw.Attacker.Username) {
-		gs.removeUnitsInLocation(overlappingLocation)
-		fmt.Printf("Your units in %s have been killed.\n", overlappingLocation)
+// HandleWarResult brings this player up to date with a war another player
+// resolved, and applies its own losses if it was a defender. It reports
+// false for wars this player did not defend.
+func (gs *GameState) HandleWarResult(report WarReport) bool {
+	gs.observeWarReport(report)
+	if !slices.Contains(report.Defenders, gs.GetUsername()) {
+		return false
 	}
 
-	return outcome, winner, loser
+	defer fmt.Println("------------------------")
+	fmt.Println()
+	fmt.Println("==== War Result ====")
+	fmt.Printf("%s attacked you in %s.\n", report.Attacker, report.Location)
+	displayWarReport(report)
+	gs.applyWarReport(report)
+	displayWarOutcome(report, gs.GetUsername())
+	return true
 }
 
 func isValidWarParticipant(player Player, rw RecognitionOfWar) bool {
 	if player.Username == rw.Defender.Username {
-		fmt.Printf("%s, you published the war.\n", player.Username)
+		fmt.Printf("%s, you are defending in this war.\n", player.Username)
 		return false
 	}
 
-	if player.Username != rw.Attacker.Username && player.Username != rw.Defender.Username {
+	if player.Username != rw.Attacker.Username {
 		fmt.Printf("%s, you are not involved in this war.\n", player.Username)
 		return false
 	}
@@ -91,38 +181,31 @@ func getUnitsAtLocation(units map[int]Unit, location Location) []Unit {
 	return locationUnits
 }
 
-func displayWarUnits(username string, units []Unit) {
+func displayWarUnits(username string, units []Unit, rules Rules) {
 	fmt.Printf("%s's units:\n", username)
 	for _, unit := range units {
-		fmt.Printf("  * %v\n", unit.Rank)
+		fmt.Printf("  * %v (%d/%d HP)\n", unit.Rank, rules.health(unit), rules.maxHealth(unit.Rank))
 	}
 }
 
-func calculatePowerLevel(units []Unit) int {
-	power := 0
-	for _, unit := range units {
-		power += UnitPower[unit.Rank]
+func displayWarReport(report WarReport) {
+	fmt.Printf("The war in %s lasted %d round(s):\n", report.Location, report.Rounds)
+	for _, fate := range report.Fates {
+		fmt.Printf("  * %s\n", fate)
 	}
-	return power
 }
 
-func determineWarOutcome(attackerPower, defenderPower int, rw RecognitionOfWar, currentPlayer string) (WarOutcome, string, string) {
-	if attackerPower > defenderPower {
-		fmt.Printf("%s has won the war!\n", rw.Attacker.Username)
-		if currentPlayer == rw.Defender.Username {
-			fmt.Println("You have lost the war!")
-			return WarOutcomeOpponentWon, rw.Attacker.Username, rw.Defender.Username
-		}
-		return WarOutcomeYouWon, rw.Attacker.Username, rw.Defender.Username
-	} else if defenderPower > attackerPower {
-		fmt.Printf("%s has won the war!\n", rw.Defender.Username)
-		if currentPlayer == rw.Attacker.Username {
-			fmt.Println("You have lost the war!")
-			return WarOutcomeOpponentWon, rw.Defender.Username, rw.Attacker.Username
-		}
-		return WarOutcomeYouWon, rw.Defender.Username, rw.Attacker.Username
+// displayWarOutcome prints how the war went for every participant
+func displayWarOutcome(report WarReport, currentPlayer string) {
+	if report.Draw {
+		fmt.Println("The war ended in a draw!")
+		return
+	}
+	fmt.Printf("%s won the war!\n", joinNames(report.Winners))
+	for _, team := range report.teams() {
+		fmt.Printf("  %s: %d power left\n", joinNames(report.members(team)), report.strength(team))
+	}
+	if report.Outcome(currentPlayer) == WarOutcomeOpponentWon {
+		fmt.Println("You have lost the war!")
 	}
-
-	fmt.Println("The war ended in a draw!")
-	return WarOutcomeDraw, rw.Attacker.Username, rw.Defender.Username
 }
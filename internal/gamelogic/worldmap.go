@@ -0,0 +1,320 @@
+package gamelogic
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed maps
+var builtinMaps embed.FS
+
+// DefaultMapName is the six continent map the game started with
+const DefaultMapName = "classic"
+
+// UnitMoveRange is how many links a unit of each rank may cross in one
+// move. Ranks not listed move one step.
+var UnitMoveRange = map[UnitRank]int{
+	RankCavalry: 2,
+}
+
+// Link is one edge of the map. Sea links join territories across water.
+type Link struct {
+	To  Location
+	Sea bool
+}
+
+// Territory is one place units can be. Properties hold whatever extra
+// rules a map wants to attach, such as terrain.
+type Territory struct {
+	Name       Location
+	Region     string
+	Properties map[string]string
+}
+
+// Region is a group of territories. Holding all of them is worth Bonus.
+type Region struct {
+	Name        string
+	Bonus       int
+	Territories []Location
+}
+
+// Map is a set of territories and the links between them. Links go both
+// ways.
+type Map struct {
+	Name        string
+	territories []Location
+	info        map[Location]Territory
+	links       map[Location][]Link
+	regions     []Region
+	starts      []Location
+	def         mapFile
+}
+
+// mapFile is the file form of a map, in JSON or YAML
+type mapFile struct {
+	Name        string `json:"name" yaml:"name"`
+	Territories []struct {
+		Name       Location          `json:"name" yaml:"name"`
+		Properties map[string]string `json:"properties,omitempty" yaml:"properties,omitempty"`
+	} `json:"territories" yaml:"territories"`
+	Regions []struct {
+		Name        string     `json:"name" yaml:"name"`
+		Bonus       int        `json:"bonus" yaml:"bonus"`
+		Territories []Location `json:"territories" yaml:"territories"`
+	} `json:"regions,omitempty" yaml:"regions,omitempty"`
+	Links []struct {
+		From Location `json:"from" yaml:"from"`
+		To   Location `json:"to" yaml:"to"`
+		Sea  bool     `json:"sea,omitempty" yaml:"sea,omitempty"`
+	} `json:"links" yaml:"links"`
+	StartingPositions []Location `json:"starting_positions,omitempty" yaml:"starting_positions,omitempty"`
+}
+
+// ParseMap reads a map in JSON or YAML. YAML is a superset of JSON, so one
+// parser handles both.
+func ParseMap(data []byte) (*Map, error) {
+	var f mapFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse map: %w", err)
+	}
+	if f.Name == "" {
+		return nil, errors.New("map has no name")
+	}
+	if len(f.Territories) == 0 {
+		return nil, fmt.Errorf("map %s has no territories", f.Name)
+	}
+
+	m := &Map{
+		Name:  f.Name,
+		info:  map[Location]Territory{},
+		links: map[Location][]Link{},
+		def:   f,
+	}
+	for _, t := range f.Territories {
+		if t.Name == "" {
+			return nil, fmt.Errorf("map %s has a territory with no name", f.Name)
+		}
+		if m.Has(t.Name) {
+			return nil, fmt.Errorf("map %s lists %s twice", f.Name, t.Name)
+		}
+		m.info[t.Name] = Territory{Name: t.Name, Properties: t.Properties}
+		m.links[t.Name] = []Link{}
+		m.territories = append(m.territories, t.Name)
+	}
+	sort.Slice(m.territories, func(i, j int) bool { return m.territories[i] < m.territories[j] })
+
+	for _, r := range f.Regions {
+		if r.Name == "" {
+			return nil, fmt.Errorf("map %s has a region with no name", f.Name)
+		}
+		for _, loc := range r.Territories {
+			t, ok := m.info[loc]
+			if !ok {
+				return nil, fmt.Errorf("map %s region %s has unknown territory %s", f.Name, r.Name, loc)
+			}
+			if t.Region != "" {
+				return nil, fmt.Errorf("map %s puts %s in both %s and %s", f.Name, loc, t.Region, r.Name)
+			}
+			t.Region = r.Name
+			m.info[loc] = t
+		}
+		m.regions = append(m.regions, Region{
+			Name:        r.Name,
+			Bonus:       r.Bonus,
+			Territories: append([]Location(nil), r.Territories...),
+		})
+	}
+
+	for _, l := range f.Links {
+		if !m.Has(l.From) || !m.Has(l.To) {
+			return nil, fmt.Errorf("map %s links unknown territory in %s-%s", f.Name, l.From, l.To)
+		}
+		if l.From == l.To {
+			return nil, fmt.Errorf("map %s links %s to itself", f.Name, l.From)
+		}
+		m.links[l.From] = append(m.links[l.From], Link{To: l.To, Sea: l.Sea})
+		m.links[l.To] = append(m.links[l.To], Link{To: l.From, Sea: l.Sea})
+	}
+
+	for _, loc := range f.StartingPositions {
+		if !m.Has(loc) {
+			return nil, fmt.Errorf("map %s starts on unknown territory %s", f.Name, loc)
+		}
+		m.starts = append(m.starts, loc)
+	}
+	return m, nil
+}
+
+// LoadMap returns the built-in map called name, or reads name as a map file
+func LoadMap(name string) (*Map, error) {
+	for _, ext := range []string{".json", ".yaml"} {
+		if data, err := builtinMaps.ReadFile("maps/" + name + ext); err == nil {
+			return ParseMap(data)
+		}
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("no built-in map or map file named %s: %w", name, err)
+	}
+	return ParseMap(data)
+}
+
+// MarshalJSON encodes the map in its file form, so it can be sent to
+// players and read back with ParseMap
+func (m *Map) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.def)
+}
+
+// DefaultMap returns a fresh copy of the classic map
+func DefaultMap() *Map {
+	m, err := LoadMap(DefaultMapName)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// Locations lists every territory in a stable order
+func (m *Map) Locations() []Location {
+	return append([]Location(nil), m.territories...)
+}
+
+func (m *Map) Has(loc Location) bool {
+	_, ok := m.links[loc]
+	return ok
+}
+
+func (m *Map) Territory(loc Location) (Territory, bool) {
+	t, ok := m.info[loc]
+	return t, ok
+}
+
+func (m *Map) Regions() []Region {
+	return append([]Region(nil), m.regions...)
+}
+
+// StartingPositions lists where a player's first unit may be spawned. Empty
+// means anywhere.
+func (m *Map) StartingPositions() []Location {
+	return append([]Location(nil), m.starts...)
+}
+
+// Bonus adds up the bonuses of every region whose territories are all in
+// held
+func (m *Map) Bonus(held map[Location]bool) int {
+	bonus := 0
+	for _, r := range m.regions {
+		all := true
+		for _, loc := range r.Territories {
+			if !held[loc] {
+				all = false
+				break
+			}
+		}
+		if all {
+			bonus += r.Bonus
+		}
+	}
+	return bonus
+}
+
+// Neighbors lists the territories one link away from loc
+func (m *Map) Neighbors(loc Location) []Link {
+	return append([]Link(nil), m.links[loc]...)
+}
+
+// Path returns the shortest route from one territory to another, without
+// from itself, or nil if there is none
+func (m *Map) Path(from, to Location) []Location {
+	if !m.Has(from) || !m.Has(to) {
+		return nil
+	}
+	if from == to {
+		return []Location{}
+	}
+
+	prev := map[Location]Location{from: ""}
+	queue := []Location{from}
+	for len(queue) > 0 {
+		loc := queue[0]
+		queue = queue[1:]
+		for _, l := range m.links[loc] {
+			if _, seen := prev[l.To]; seen {
+				continue
+			}
+			prev[l.To] = loc
+			if l.To == to {
+				path := []Location{}
+				for at := to; at != from; at = prev[at] {
+					path = append([]Location{at}, path...)
+				}
+				return path
+			}
+			queue = append(queue, l.To)
+		}
+	}
+	return nil
+}
+
+// Distance is the number of links between two territories, or -1 if one
+// cannot be reached from the other
+func (m *Map) Distance(from, to Location) int {
+	path := m.Path(from, to)
+	if path == nil {
+		return -1
+	}
+	return len(path)
+}
+
+// MoveRange is how many links a unit of rank may cross in one move
+func MoveRange(rank UnitRank) int {
+	if r, ok := UnitMoveRange[rank]; ok {
+		return r
+	}
+	return 1
+}
+
+// CanMove reports why unit cannot move to loc, or nil if it can
+func (m *Map) CanMove(unit Unit, loc Location) error {
+	if !m.Has(loc) {
+		return fmt.Errorf("error: %s is not a valid location", loc)
+	}
+	if unit.Location == loc {
+		return fmt.Errorf("error: unit %v is already in %s", unit.ID, loc)
+	}
+	d := m.Distance(unit.Location, loc)
+	if d < 0 || d > MoveRange(unit.Rank) {
+		return fmt.Errorf("error: %s in %s can not reach %s in one move", unit.Rank, unit.Location, loc)
+	}
+	return nil
+}
+
+// Reachable lists the territories within steps links of from, without from
+// itself, in a stable order
+func (m *Map) Reachable(from Location, steps int) []Location {
+	seen := map[Location]bool{from: true}
+	frontier := []Location{from}
+	reached := []Location{}
+	for i := 0; i < steps; i++ {
+		next := []Location{}
+		for _, loc := range frontier {
+			for _, l := range m.links[loc] {
+				if seen[l.To] {
+					continue
+				}
+				seen[l.To] = true
+				next = append(next, l.To)
+				reached = append(reached, l.To)
+			}
+		}
+		frontier = next
+	}
+	sort.Slice(reached, func(i, j int) bool { return reached[i] < reached[j] })
+	return reached
+}
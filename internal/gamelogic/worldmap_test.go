@@ -0,0 +1,91 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const islandsMap = `
+name: islands
+territories:
+  - name: north
+    properties: {terrain: hills}
+  - name: south
+  - name: east
+regions:
+  - name: mainland
+    bonus: 2
+    territories: [north, south]
+links:
+  - {from: north, to: south}
+  - {from: south, to: east, sea: true}
+starting_positions: [north, east]
+`
+
+func TestParseMap(t *testing.T) {
+	m, err := ParseMap([]byte(islandsMap))
+	if err != nil {
+		t.Fatalf("ParseMap: %v", err)
+	}
+	if want := []Location{"east", "north", "south"}; !reflect.DeepEqual(m.Locations(), want) {
+		t.Errorf("locations %v, want %v", m.Locations(), want)
+	}
+	if north, _ := m.Territory("north"); north.Region != "mainland" || north.Properties["terrain"] != "hills" {
+		t.Errorf("north is %+v", north)
+	}
+	if d := m.Distance("north", "east"); d != 2 {
+		t.Errorf("north to east is %d links, want 2", d)
+	}
+	if bonus := m.Bonus(map[Location]bool{"north": true, "south": true}); bonus != 2 {
+		t.Errorf("holding the mainland is worth %d, want 2", bonus)
+	}
+	if want := []Location{"north", "east"}; !reflect.DeepEqual(m.StartingPositions(), want) {
+		t.Errorf("starting positions %v, want %v", m.StartingPositions(), want)
+	}
+
+	// Players get the map as JSON and must read back the same one
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ParseMap(data)
+	if err != nil {
+		t.Fatalf("ParseMap of the JSON form: %v", err)
+	}
+	if !reflect.DeepEqual(again.Locations(), m.Locations()) || !reflect.DeepEqual(again.Neighbors("south"), m.Neighbors("south")) {
+		t.Errorf("JSON form read back as %+v", again)
+	}
+}
+
+func TestParseMapErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+	}{
+		{"no name", `territories: [{name: a}]`},
+		{"no territories", `name: empty`},
+		{"territory twice", `{name: m, territories: [{name: a}, {name: a}]}`},
+		{"unknown link", `{name: m, territories: [{name: a}], links: [{from: a, to: b}]}`},
+		{"link to itself", `{name: m, territories: [{name: a}], links: [{from: a, to: a}]}`},
+		{"region twice", `{name: m, territories: [{name: a}], regions: [{name: r, territories: [a]}, {name: s, territories: [a]}]}`},
+		{"unknown start", `{name: m, territories: [{name: a}], starting_positions: [b]}`},
+		{"not yaml", `{`},
+	}
+	for _, tt := range tests {
+		if _, err := ParseMap([]byte(tt.def)); err == nil {
+			t.Errorf("%s: parsed without error", tt.name)
+		}
+	}
+}
+
+func TestBuiltinMapsLoad(t *testing.T) {
+	for _, name := range []string{"classic", "risk"} {
+		if _, err := LoadMap(name); err != nil {
+			t.Errorf("LoadMap(%s): %v", name, err)
+		}
+	}
+	if _, err := LoadMap("no-such-map"); err == nil {
+		t.Error("loaded a map that does not exist")
+	}
+}
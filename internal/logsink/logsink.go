@@ -0,0 +1,344 @@
+package logsink
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+type Format string
+
+const (
+	// FormatText writes "time [instance] username: message" lines
+	FormatText Format = "text"
+	// FormatJSONLines writes one JSON object per line with every field of
+	// routing.GameLog
+	FormatJSONLines Format = "jsonl"
+)
+
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs after every write
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval fsyncs in the background every SyncInterval
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system
+	SyncNever SyncPolicy = "never"
+)
+
+// rotatedTimeFormat is appended to the path of rotated files. It sorts
+// lexically in time order.
+const rotatedTimeFormat = "20060102T150405.000000000"
+
+// rename is os.Rename, swapped out by tests
+var rename = os.Rename
+
+type Config struct {
+	Path   string
+	Format Format
+	// MaxSize rotates the file before it grows past this many bytes
+	MaxSize int64
+	// MaxAge rotates the file once it is this old. A file left by an earlier
+	// run counts from when it was last written.
+	MaxAge time.Duration
+	// MaxBackups is how many rotated files to keep. Zero keeps them all.
+	MaxBackups   int
+	Sync         SyncPolicy
+	SyncInterval time.Duration
+	// Instance names the server writing the file and is stamped on every entry
+	Instance string
+}
+
+// Record is one line of JSON-lines output
+type Record struct {
+	routing.GameLog
+	Instance string `json:",omitempty"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Path:         "game.log",
+		Format:       FormatJSONLines,
+		MaxSize:      10 << 20,
+		MaxAge:       24 * time.Hour,
+		MaxBackups:   7,
+		Sync:         SyncInterval,
+		SyncInterval: time.Second,
+	}
+}
+
+// Sink appends game logs to a file through one long-lived handle. It is
+// safe for concurrent writers.
+type Sink struct {
+	cfg    Config
+	mu     *sync.Mutex
+	f      *os.File
+	size   int64
+	opened time.Time
+	dirty  bool
+	closed bool
+	done   chan struct{}
+}
+
+func Open(cfg Config) (*Sink, error) {
+	switch cfg.Format {
+	case FormatText, FormatJSONLines:
+	default:
+		return nil, fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	switch cfg.Sync {
+	case SyncAlways, SyncNever:
+	case SyncInterval:
+		if cfg.SyncInterval <= 0 {
+			return nil, fmt.Errorf("sync interval must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown sync policy %q", cfg.Sync)
+	}
+
+	s := &Sink{
+		cfg:  cfg,
+		mu:   &sync.Mutex{},
+		done: make(chan struct{}),
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	if cfg.Sync == SyncInterval {
+		go s.syncLoop()
+	}
+	return s, nil
+}
+
+func (s *Sink) open() error {
+	if dir := filepath.Dir(s.cfg.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("could not create log directory: %w", err)
+		}
+	}
+
+	f, err := os.OpenFile(s.cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open logs file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not stat logs file: %w", err)
+	}
+
+	s.f = f
+	s.size = info.Size()
+	s.opened = time.Now()
+	if s.size > 0 {
+		s.opened = info.ModTime()
+	}
+	return nil
+}
+
+// Write appends gamelog, rotating the file first if it is due
+func (s *Sink) Write(gamelog routing.GameLog) error {
+	line, err := s.format(gamelog)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("log sink is closed")
+	}
+	if s.f == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.dueForRotation(int64(len(line))) {
+		if err := s.rotate(); err != nil {
+			if s.f == nil {
+				return err
+			}
+			// Keep logging to the current file and try again next write
+			fmt.Printf("%v, still writing to %s\n", err, s.cfg.Path)
+		}
+	}
+
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("could not write to logs file: %w", err)
+	}
+
+	if s.cfg.Sync == SyncAlways {
+		if err := s.f.Sync(); err != nil {
+			return fmt.Errorf("could not sync logs file: %w", err)
+		}
+	} else {
+		s.dirty = true
+	}
+	return nil
+}
+
+func (s *Sink) format(gamelog routing.GameLog) ([]byte, error) {
+	if s.cfg.Format == FormatText {
+		return []byte(Record{GameLog: gamelog, Instance: s.cfg.Instance}.Text()), nil
+	}
+
+	line, err := json.Marshal(Record{GameLog: gamelog, Instance: s.cfg.Instance})
+	if err != nil {
+		return nil, fmt.Errorf("could not encode game log: %w", err)
+	}
+	return append(line, '\n'), nil
+}
+
+func (s *Sink) dueForRotation(next int64) bool {
+	if s.size == 0 {
+		return false
+	}
+	if s.cfg.MaxSize > 0 && s.size+next > s.cfg.MaxSize {
+		return true
+	}
+	return s.cfg.MaxAge > 0 && time.Since(s.opened) >= s.cfg.MaxAge
+}
+
+// rotate renames the current file aside, opens a fresh one and prunes old
+// backups. It is called with s.mu held. If the rename fails the current
+// file is opened again, so s.f is only left nil when no file can be opened.
+func (s *Sink) rotate() error {
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("could not sync logs file: %w", err)
+	}
+	err := s.f.Close()
+	s.f = nil
+	if err != nil {
+		return fmt.Errorf("could not close logs file: %w", err)
+	}
+
+	rotated := fmt.Sprintf("%s.%s", s.cfg.Path, time.Now().UTC().Format(rotatedTimeFormat))
+	if err := rename(s.cfg.Path, rotated); err != nil {
+		if openErr := s.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("could not rotate logs file: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+	// The entry can still be written, so a failed prune is only reported
+	if err := s.prune(); err != nil {
+		fmt.Printf("could not prune old logs: %v\n", err)
+	}
+	return nil
+}
+
+func (s *Sink) prune() error {
+	if s.cfg.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := Backups(s.cfg.Path)
+	if err != nil {
+		return err
+	}
+	for len(backups) > s.cfg.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return fmt.Errorf("could not remove old log %s: %w", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Text renders r as a line of plain text
+func (r Record) Text() string {
+	if r.Instance == "" {
+		return fmt.Sprintf("%v %v: %v\n", r.CurrentTime.Format(time.RFC3339), r.Username, r.Message)
+	}
+	return fmt.Sprintf("%v [%v] %v: %v\n", r.CurrentTime.Format(time.RFC3339), r.Instance, r.Username, r.Message)
+}
+
+// ReadRecords parses JSON-lines output
+func ReadRecords(r io.Reader) ([]Record, error) {
+	records := []Record{}
+	dec := json.NewDecoder(r)
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not parse record %d: %w", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+}
+
+// Backups lists the rotated files for path, oldest first
+func Backups(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []string{}
+	prefix := path + "."
+	for _, m := range matches {
+		if _, err := time.Parse(rotatedTimeFormat, strings.TrimPrefix(m, prefix)); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func (s *Sink) syncLoop() {
+	ticker := time.NewTicker(s.cfg.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.f != nil && s.dirty {
+				if err := s.f.Sync(); err != nil {
+					fmt.Printf("could not sync logs file: %v\n", err)
+				}
+				s.dirty = false
+			}
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Close flushes and closes the file
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Sync()
+	if closeErr := s.f.Close(); err == nil {
+		err = closeErr
+	}
+	s.f = nil
+	return err
+}
@@ -0,0 +1,170 @@
+package logsink
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func testConfig(t *testing.T) Config {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Path = filepath.Join(t.TempDir(), "game.log")
+	cfg.Sync = SyncNever
+	return cfg
+}
+
+func entry(msg string) routing.GameLog {
+	return routing.GameLog{CurrentTime: time.Now(), Username: "alice", Message: msg}
+}
+
+func readAll(t *testing.T, path string) []Record {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+	records, err := ReadRecords(f)
+	if err != nil {
+		t.Fatalf("ReadRecords: %v", err)
+	}
+	return records
+}
+
+func TestRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxSize     int64
+		maxAge      time.Duration
+		existingAge time.Duration
+		writes      int
+		wantBackups int
+	}{
+		{"under the limits", 1 << 20, time.Hour, 0, 3, 0},
+		{"size", 1, 0, 0, 5, 4},
+		{"new file is young", 0, time.Hour, 0, 3, 0},
+		{"existing file is old", 0, time.Hour, 2 * time.Hour, 1, 1},
+		{"existing file is recent", 0, time.Hour, time.Minute, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			cfg.MaxSize = tt.maxSize
+			cfg.MaxAge = tt.maxAge
+			if tt.existingAge > 0 {
+				if err := os.WriteFile(cfg.Path, []byte("{}\n"), 0644); err != nil {
+					t.Fatalf("WriteFile: %v", err)
+				}
+				then := time.Now().Add(-tt.existingAge)
+				if err := os.Chtimes(cfg.Path, then, then); err != nil {
+					t.Fatalf("Chtimes: %v", err)
+				}
+			}
+
+			s, err := Open(cfg)
+			if err != nil {
+				t.Fatalf("Open: %v", err)
+			}
+			defer s.Close()
+			for i := 0; i < tt.writes; i++ {
+				if err := s.Write(entry("a war broke out in europe")); err != nil {
+					t.Fatalf("Write %d: %v", i, err)
+				}
+			}
+
+			backups, err := Backups(cfg.Path)
+			if err != nil {
+				t.Fatalf("Backups: %v", err)
+			}
+			if len(backups) != tt.wantBackups {
+				t.Fatalf("got %d backups, want %d", len(backups), tt.wantBackups)
+			}
+		})
+	}
+}
+
+func TestFailedRotationKeepsWriting(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.MaxSize = 100
+
+	failing := true
+	rename = func(from, to string) error {
+		if failing {
+			return errors.New("disk says no")
+		}
+		return os.Rename(from, to)
+	}
+	defer func() { rename = os.Rename }()
+
+	s, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 4; i++ {
+		if err := s.Write(entry("still here")); err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+	if got := len(readAll(t, cfg.Path)); got != 4 {
+		t.Fatalf("wrote %d entries to the current file, want 4", got)
+	}
+
+	failing = false
+	if err := s.Write(entry("rotated at last")); err != nil {
+		t.Fatalf("Write after recovery: %v", err)
+	}
+	backups, err := Backups(cfg.Path)
+	if err != nil {
+		t.Fatalf("Backups: %v", err)
+	}
+	if len(backups) != 1 || len(readAll(t, backups[0])) != 4 || len(readAll(t, cfg.Path)) != 1 {
+		t.Fatalf("rotation after recovery left backups %v", backups)
+	}
+}
+
+func TestPruneKeepsMaxBackups(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.MaxSize = 100
+	cfg.MaxBackups = 2
+
+	s, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	for i := 0; i < 6; i++ {
+		if err := s.Write(entry("one entry per file")); err != nil {
+			t.Fatalf("Write %d: %v", i, err)
+		}
+	}
+	backups, err := Backups(cfg.Path)
+	if err != nil {
+		t.Fatalf("Backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("kept %d backups, want 2", len(backups))
+	}
+}
+
+func TestWriteAfterClose(t *testing.T) {
+	s, err := Open(testConfig(t))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := s.Write(entry("too late")); err == nil {
+		t.Fatal("wrote to a closed sink")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package logstore
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	_ "modernc.org/sqlite"
+)
+
+const DefaultLimit = 50
+
+const schema = `
+CREATE TABLE IF NOT EXISTS game_logs (
+	id       INTEGER PRIMARY KEY,
+	time     INTEGER NOT NULL,
+	username TEXT NOT NULL,
+	message  TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS game_logs_time ON game_logs (time);
+CREATE INDEX IF NOT EXISTS game_logs_username_time ON game_logs (username, time);
+`
+
+// uniqueIndex makes inserting a redelivered log a no-op. Databases from
+// before it may hold such duplicates, which have to go first.
+const uniqueIndex = `
+DELETE FROM game_logs WHERE id NOT IN (
+	SELECT MIN(id) FROM game_logs GROUP BY time, username, message
+);
+CREATE UNIQUE INDEX game_logs_entry ON game_logs (time, username, message);
+`
+
+// Store keeps game logs in an embedded SQLite database
+type Store struct {
+	db *sql.DB
+}
+
+func Open(path string) (*Store, error) {
+	// WAL lets queries run while the consumer keeps inserting
+	dsn := fmt.Sprintf("file:%s?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("could not open log database: %w", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create log schema: %w", err)
+	}
+	if err := addUniqueIndex(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+func addUniqueIndex(db *sql.DB) error {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'game_logs_entry'").Scan(&n)
+	if err != nil {
+		return fmt.Errorf("could not check log schema: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := db.Exec(uniqueIndex); err != nil {
+		return fmt.Errorf("could not create log schema: %w", err)
+	}
+	return nil
+}
+
+// Insert stores gamelog. Storing the same log again, as happens when a
+// message is redelivered, does nothing.
+func (s *Store) Insert(gamelog routing.GameLog) error {
+	_, err := s.db.Exec(
+		"INSERT OR IGNORE INTO game_logs (time, username, message) VALUES (?, ?, ?)",
+		gamelog.CurrentTime.UnixNano(),
+		gamelog.Username,
+		gamelog.Message,
+	)
+	if err != nil {
+		return fmt.Errorf("could not insert game log: %w", err)
+	}
+	return nil
+}
+
+// Query filters game logs. Zero values match everything.
+type Query struct {
+	Username string
+	Since    time.Time
+	// Grep matches a case-insensitive substring of the message
+	Grep  string
+	Limit int
+}
+
+// Find returns the logs matching q, newest first
+func (s *Store) Find(q Query) ([]routing.GameLog, error) {
+	where := []string{}
+	args := []any{}
+	if q.Username != "" {
+		where = append(where, "username = ?")
+		args = append(args, q.Username)
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "time >= ?")
+		args = append(args, q.Since.UnixNano())
+	}
+	if q.Grep != "" {
+		where = append(where, `message LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(q.Grep)+"%")
+	}
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	query := "SELECT time, username, message FROM game_logs"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY time DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query game logs: %w", err)
+	}
+	defer rows.Close()
+
+	logs := []routing.GameLog{}
+	for rows.Next() {
+		var nanos int64
+		var gl routing.GameLog
+		if err := rows.Scan(&nanos, &gl.Username, &gl.Message); err != nil {
+			return nil, fmt.Errorf("could not read game log: %w", err)
+		}
+		gl.CurrentTime = time.Unix(0, nanos)
+		logs = append(logs, gl)
+	}
+	return logs, rows.Err()
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
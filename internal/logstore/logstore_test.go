@@ -0,0 +1,123 @@
+package logstore
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func openTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "game.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, path
+}
+
+func TestInsertIgnoresRedelivery(t *testing.T) {
+	s, _ := openTestStore(t)
+	gl := routing.GameLog{CurrentTime: time.Unix(100, 5), Username: "alice", Message: "alice won a war"}
+
+	for i := 0; i < 3; i++ {
+		if err := s.Insert(gl); err != nil {
+			t.Fatalf("Insert %d: %v", i, err)
+		}
+	}
+	other := gl
+	other.Message = "alice lost a war"
+	if err := s.Insert(other); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	logs, err := s.Find(Query{})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("got %d logs, want 2: %+v", len(logs), logs)
+	}
+}
+
+func TestOpenRemovesOldDuplicates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := db.Exec("INSERT INTO game_logs (time, username, message) VALUES (1, 'bob', 'dup')"); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+	db.Close()
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	logs, err := s.Find(Query{})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Fatalf("got %d logs after opening, want 1", len(logs))
+	}
+}
+
+func TestFind(t *testing.T) {
+	s, _ := openTestStore(t)
+	base := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	for i, gl := range []routing.GameLog{
+		{CurrentTime: base, Username: "alice", Message: "alice won a war against bob"},
+		{CurrentTime: base.Add(time.Hour), Username: "bob", Message: "bob won a war against alice"},
+		{CurrentTime: base.Add(2 * time.Hour), Username: "alice", Message: "100% of carol's units died"},
+	} {
+		if err := s.Insert(gl); err != nil {
+			t.Fatalf("Insert %d: %v", i, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"everything, newest first", Query{}, []string{"alice", "bob", "alice"}},
+		{"by user", Query{Username: "bob"}, []string{"bob"}},
+		{"since", Query{Since: base.Add(30 * time.Minute)}, []string{"alice", "bob"}},
+		{"grep phrase", Query{Grep: "against alice"}, []string{"bob"}},
+		{"grep is case-insensitive", Query{Grep: "ALICE WON"}, []string{"alice"}},
+		{"grep escapes wildcards", Query{Grep: "100%"}, []string{"alice"}},
+		{"limit", Query{Limit: 1}, []string{"alice"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := s.Find(tt.q)
+			if err != nil {
+				t.Fatalf("Find: %v", err)
+			}
+			got := []string{}
+			for _, gl := range logs {
+				got = append(got, gl.Username)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	DefaultRetryDelay = 2 * time.Second
+	publishTimeout    = 10 * time.Second
+)
+
+// Confirmer publishes a message and returns once the broker has confirmed it
+type Confirmer interface {
+	PublishConfirmed(ctx context.Context, exchange, key string, msg amqp.Publishing) error
+}
+
+type Config struct {
+	// OnCommit is called once the broker has confirmed an entry
+	OnCommit func(Entry)
+	// OnRollback is called when the broker rejects an entry. Nothing else
+	// rolls an entry back: after a timeout the broker may well have the
+	// message, so it is published again instead.
+	OnRollback func(Entry, error)
+	// OnRetry is called each time publishing an entry fails without the
+	// broker rejecting it, before it is tried again
+	OnRetry func(e Entry, attempt int, err error)
+	// Snapshot, if set, is saved with every entry, in the same write, so the
+	// local change an entry describes cannot be lost while the entry is kept
+	Snapshot func() ([]byte, error)
+	// Headers are added to every message as it is published rather than
+	// stored with it, so short-lived values such as a session token are
+	// current even for entries left over from an earlier run
+	Headers    amqp.Table
+	RetryDelay time.Duration
+}
+
+// Outbox drains a Store in order through a confirming publisher. Callers
+// apply their local change and Enqueue the message describing it in one
+// step; the change only counts as committed once the broker confirms it.
+type Outbox struct {
+	store     *Store
+	publisher Confirmer
+	cfg       Config
+	wake      chan struct{}
+}
+
+func New(store *Store, publisher Confirmer, cfg Config) *Outbox {
+	if cfg.RetryDelay <= 0 {
+		cfg.RetryDelay = DefaultRetryDelay
+	}
+	return &Outbox{
+		store:     store,
+		publisher: publisher,
+		cfg:       cfg,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Enqueue encodes val with codec and stores it with undo, the data needed
+// to roll the local change back. Apply the local change first, so the
+// snapshot saved with the entry includes it.
+func Enqueue[T any](o *Outbox, exchange, key string, codec pubsub.Codec, val T, undo any) (Entry, error) {
+	var msg amqp.Publishing
+	if err := codec.Marshal(val, &msg); err != nil {
+		return Entry{}, err
+	}
+
+	undoData, err := json.Marshal(undo)
+	if err != nil {
+		return Entry{}, fmt.Errorf("could not encode undo data: %w", err)
+	}
+
+	var state []byte
+	if o.cfg.Snapshot != nil {
+		state, err = o.cfg.Snapshot()
+		if err != nil {
+			return Entry{}, err
+		}
+	}
+
+	e, err := o.store.Add(Entry{
+		Exchange: exchange,
+		Key:      key,
+		Message:  msg,
+		Undo:     undoData,
+	}, state)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return e, nil
+}
+
+// Pending is the number of entries waiting for confirmation
+func (o *Outbox) Pending() int {
+	return len(o.store.Pending())
+}
+
+// Run drains the outbox until ctx is cancelled
+func (o *Outbox) Run(ctx context.Context) {
+	for {
+		for _, e := range o.store.Pending() {
+			if !o.deliver(ctx, e) {
+				return
+			}
+		}
+
+		select {
+		case <-o.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver publishes e until the broker confirms or rejects it. Any other
+// failure, a timeout included, is retried, since the broker may have taken
+// the message and rolling back would undo a move the others have seen. It
+// reports false only when ctx is cancelled.
+func (o *Outbox) deliver(ctx context.Context, e Entry) bool {
+	for attempt := 1; ; attempt++ {
+		pubCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		err := o.publisher.PublishConfirmed(pubCtx, e.Exchange, e.Key, o.withHeaders(e.Message))
+		cancel()
+
+		if err == nil || errors.Is(err, pubsub.ErrNacked) {
+			o.settle(e, err)
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if o.cfg.OnRetry != nil {
+			o.cfg.OnRetry(e, attempt, err)
+		}
+
+		select {
+		case <-time.After(o.cfg.RetryDelay):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (o *Outbox) withHeaders(msg amqp.Publishing) amqp.Publishing {
+	if len(o.cfg.Headers) == 0 {
+		return msg
+	}
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	for k, v := range o.cfg.Headers {
+		headers[k] = v
+	}
+	msg.Headers = headers
+	return msg
+}
+
+func (o *Outbox) settle(e Entry, err error) {
+	if rmErr := o.store.Remove(e.ID); rmErr != nil {
+		fmt.Printf("failed to remove outbox entry %d: %v\n", e.ID, rmErr)
+	}
+
+	if err == nil {
+		if o.cfg.OnCommit != nil {
+			o.cfg.OnCommit(e)
+		}
+		return
+	}
+	if o.cfg.OnRollback != nil {
+		o.cfg.OnRollback(e, err)
+	}
+}
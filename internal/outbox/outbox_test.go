@@ -0,0 +1,192 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// fakeConfirmer answers each publish with the next error in results, then
+// with nil once they run out
+type fakeConfirmer struct {
+	mu      sync.Mutex
+	results []error
+	sent    []amqp.Publishing
+}
+
+func (f *fakeConfirmer) PublishConfirmed(_ context.Context, _, _ string, msg amqp.Publishing) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	if len(f.results) == 0 {
+		return nil
+	}
+	err := f.results[0]
+	f.results = f.results[1:]
+	return err
+}
+
+type outcome struct {
+	committed  int
+	rolledBack []error
+	retries    int
+}
+
+func TestOutboxSettles(t *testing.T) {
+	timeout := context.DeadlineExceeded
+	tests := []struct {
+		name    string
+		results []error
+		want    outcome
+	}{
+		{"confirmed", nil, outcome{committed: 1}},
+		{"nacked", []error{pubsub.ErrNacked}, outcome{rolledBack: []error{pubsub.ErrNacked}}},
+		{"timeouts then confirmed", []error{timeout, timeout, timeout, timeout, timeout, timeout}, outcome{committed: 1, retries: 6}},
+		{"timeout then nacked", []error{timeout, pubsub.ErrNacked}, outcome{rolledBack: []error{pubsub.ErrNacked}, retries: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := OpenStore(filepath.Join(t.TempDir(), "outbox.json"))
+			if err != nil {
+				t.Fatalf("OpenStore: %v", err)
+			}
+
+			var mu sync.Mutex
+			got := outcome{}
+			done := make(chan struct{}, 1)
+			confirmer := &fakeConfirmer{results: tt.results}
+			o := New(store, confirmer, Config{
+				RetryDelay: time.Millisecond,
+				OnCommit: func(Entry) {
+					mu.Lock()
+					got.committed++
+					mu.Unlock()
+					done <- struct{}{}
+				},
+				OnRollback: func(_ Entry, err error) {
+					mu.Lock()
+					got.rolledBack = append(got.rolledBack, err)
+					mu.Unlock()
+					done <- struct{}{}
+				},
+				OnRetry: func(Entry, int, error) {
+					mu.Lock()
+					got.retries++
+					mu.Unlock()
+				},
+			})
+
+			if _, err := Enqueue(o, "peril_topic", "army_moves.alice", pubsub.JSONCodec{}, "move", "undo"); err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go o.Run(ctx)
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("entry was never settled")
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if got.committed != tt.want.committed || got.retries != tt.want.retries || len(got.rolledBack) != len(tt.want.rolledBack) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			for i, err := range got.rolledBack {
+				if !errors.Is(err, tt.want.rolledBack[i]) {
+					t.Fatalf("rolled back with %v, want %v", err, tt.want.rolledBack[i])
+				}
+			}
+			if o.Pending() != 0 {
+				t.Fatalf("%d entries still pending", o.Pending())
+			}
+		})
+	}
+}
+
+func TestOutboxAddsHeadersWhenPublishing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	confirmer := &fakeConfirmer{}
+	committed := make(chan struct{}, 1)
+	o := New(store, confirmer, Config{
+		Headers:  amqp.Table{"x-token": "fresh"},
+		OnCommit: func(Entry) { committed <- struct{}{} },
+	})
+
+	codec := pubsub.WithHeaders(pubsub.JSONCodec{}, amqp.Table{"x-kept": "yes"})
+	if _, err := Enqueue(o, "peril_topic", "army_moves.alice", codec, "move", nil); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if got := store.Pending()[0].Message.Headers; got["x-token"] != nil {
+		t.Fatalf("stored headers %v include the publish-time header", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go o.Run(ctx)
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("entry was never committed")
+	}
+
+	headers := confirmer.sent[0].Headers
+	if headers["x-token"] != "fresh" || headers["x-kept"] != "yes" {
+		t.Fatalf("published headers = %v", headers)
+	}
+}
+
+func TestStoreKeepsEntriesAndSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.json")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	if store.State() != nil {
+		t.Fatalf("new store has state %s", store.State())
+	}
+
+	first, err := store.Add(Entry{Key: "a"}, json.RawMessage(`{"Revision":1}`))
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := store.Add(Entry{Key: "b"}, nil); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := store.Remove(first.ID); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	reopened, err := OpenStore(path)
+	if err != nil {
+		t.Fatalf("OpenStore: %v", err)
+	}
+	pending := reopened.Pending()
+	if len(pending) != 1 || pending[0].Key != "b" {
+		t.Fatalf("pending after reopening = %+v, want only b", pending)
+	}
+	if string(reopened.State()) != `{"Revision":1}` {
+		t.Fatalf("state after reopening = %s", reopened.State())
+	}
+
+	next, err := reopened.Add(Entry{Key: "c"}, nil)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if next.ID <= pending[0].ID {
+		t.Fatalf("reused ID %d after reopening", next.ID)
+	}
+}
@@ -0,0 +1,153 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Entry is a message waiting for the broker to confirm it, together with
+// what is needed to undo the local change it describes
+type Entry struct {
+	ID        int64
+	Exchange  string
+	Key       string
+	Message   amqp.Publishing
+	Undo      json.RawMessage
+	CreatedAt time.Time
+}
+
+// Store keeps pending entries in a JSON file so they survive a restart.
+// Every change rewrites the file through a temporary file. Alongside the
+// entries it keeps the latest snapshot of the local state they changed,
+// written in the same step as the entry that changed it.
+type Store struct {
+	path    string
+	mu      *sync.Mutex
+	nextID  int64
+	entries []Entry
+	state   json.RawMessage
+}
+
+type storeFile struct {
+	NextID  int64
+	Entries []Entry
+	State   json.RawMessage `json:",omitempty"`
+}
+
+// OpenStore loads the store at path. A missing file is an empty store.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{
+		path:   path,
+		mu:     &sync.Mutex{},
+		nextID: 1,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read outbox: %w", err)
+	}
+
+	var f storeFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("could not parse outbox: %w", err)
+	}
+	s.entries = f.Entries
+	s.state = f.State
+	if f.NextID > s.nextID {
+		s.nextID = f.NextID
+	}
+	return s, nil
+}
+
+// Add assigns e an ID and persists it, along with state if it is not nil,
+// before returning
+func (s *Store) Add(e Entry, state json.RawMessage) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state == nil {
+		state = s.state
+	}
+	e.ID = s.nextID
+	e.CreatedAt = time.Now()
+	entries := append(s.entries, e)
+	if err := s.save(s.nextID+1, entries, state); err != nil {
+		return Entry{}, err
+	}
+	s.nextID++
+	s.entries = entries
+	s.state = state
+	return e, nil
+}
+
+// State is the snapshot saved with the latest entry. It is kept after the
+// entry is removed, and is nil if no snapshot was ever saved.
+func (s *Store) State() json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// Remove deletes the entry with id
+func (s *Store) Remove(id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]Entry, 0, len(s.entries))
+	for _, e := range s.entries {
+		if e.ID != id {
+			entries = append(entries, e)
+		}
+	}
+	if err := s.save(s.nextID, entries, s.state); err != nil {
+		return err
+	}
+	s.entries = entries
+	return nil
+}
+
+// Pending returns the entries in the order they were added
+func (s *Store) Pending() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Entry(nil), s.entries...)
+}
+
+func (s *Store) save(nextID int64, entries []Entry, state json.RawMessage) error {
+	data, err := json.Marshal(storeFile{NextID: nextID, Entries: entries, State: state})
+	if err != nil {
+		return fmt.Errorf("could not encode outbox: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".outbox-*")
+	if err != nil {
+		return fmt.Errorf("could not write outbox: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write outbox: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write outbox: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write outbox: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("could not write outbox: %w", err)
+	}
+	return nil
+}
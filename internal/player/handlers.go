@@ -0,0 +1,140 @@
+package player
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+)
+
+func handlerPause(gs *gamelogic.GameState, opts Options) func(routing.PlayingState) pubsub.AckType {
+	return func(ps routing.PlayingState) pubsub.AckType {
+		defer opts.afterHandle()
+
+		gs.HandlePause(ps)
+		return pubsub.Ack
+	}
+}
+
+func handlerEconomy(gs *gamelogic.GameState) func(gamelogic.EconomyUpdate) pubsub.AckType {
+	return func(update gamelogic.EconomyUpdate) pubsub.AckType {
+		gs.HandleEconomy(update)
+		return pubsub.Ack
+	}
+}
+
+func handlerMapChanged(gs *gamelogic.GameState, opts Options) func(routing.MapResponse) pubsub.AckType {
+	return func(resp routing.MapResponse) pubsub.AckType {
+		defer opts.afterHandle()
+
+		world, err := gamelogic.ParseMap(resp.Definition)
+		if err != nil {
+			fmt.Printf("Ignoring bad map from the server: %v\n", err)
+			return pubsub.NackDiscard
+		}
+		gs.SetMap(world)
+		fmt.Println()
+		fmt.Printf("==== The game is now played on %s ====\n", world.Name)
+		return pubsub.Ack
+	}
+}
+
+func handlerTurn(gs *gamelogic.GameState, opts Options) func(gamelogic.TurnState) pubsub.AckType {
+	return func(ts gamelogic.TurnState) pubsub.AckType {
+		defer opts.afterHandle()
+
+		if opts.OnMove != nil {
+			for _, move := range ts.Moves {
+				opts.OnMove(move)
+			}
+		}
+
+		gs.HandleTurn(ts)
+		return pubsub.Ack
+	}
+}
+
+func handlerMove(gs *gamelogic.GameState, opts Options) func(gamelogic.ArmyMove) pubsub.AckType {
+	return func(am gamelogic.ArmyMove) pubsub.AckType {
+		defer opts.afterHandle()
+
+		if opts.OnMove != nil {
+			opts.OnMove(am)
+		}
+
+		outCome := gs.HandleMove(am)
+		switch outCome {
+		case gamelogic.MoveOutcomeSamePlayer:
+			return pubsub.NackDiscard
+		case gamelogic.MoveOutcomeMakeWar, gamelogic.MoveOutComeSafe:
+			// The server declares any war the move starts
+			return pubsub.Ack
+		default:
+			fmt.Println("error: unknown move outcome")
+			return pubsub.NackDiscard
+		}
+	}
+}
+
+func handlerWar(gs *gamelogic.GameState, publisher pubsub.Sender, codec, logCodec pubsub.Codec, opts Options) func(dw gamelogic.RecognitionOfWar) pubsub.AckType {
+	return func(dw gamelogic.RecognitionOfWar) pubsub.AckType {
+		defer opts.afterHandle()
+
+		outcome, report := gs.HandleWar(dw)
+		switch outcome {
+		case gamelogic.WarOutcomeNotInvolved:
+			// Wars are only routed to their attacker, so this one is bad
+			return pubsub.NackDiscard
+		case gamelogic.WarOutcomeNoUnits:
+			return pubsub.NackDiscard
+		case gamelogic.WarOutcomeOpponentWon, gamelogic.WarOutcomeYouWon, gamelogic.WarOutcomeDraw:
+			// Tell the defender what it lost. Resolving again gives the same
+			// report, so a failed publish can safely requeue the war.
+			resultKey := fmt.Sprintf("%s.%s", routing.WarResultsPrefix, gs.GetUsername())
+			err := pubsub.Publish(publisher, routing.ExchangePerilTopic, resultKey, codec, report)
+			if err != nil {
+				fmt.Printf("Failed to publish war result: %v\n", err)
+				return pubsub.NackRequeue
+			}
+
+			// Publish game log
+			logEntry := routing.GameLog{
+				CurrentTime: time.Now(),
+				Message:     report.Summary(),
+				Username:    gs.Player.Username,
+			}
+			logKey := fmt.Sprintf("%s.%s", routing.GameLogSlug, gs.Player.Username)
+			err = pubsub.Publish(publisher, routing.ExchangePerilTopic, logKey, logCodec, logEntry)
+			if err != nil {
+				fmt.Printf("Failed to publish game log: %v\n", err)
+				return pubsub.NackRequeue
+			}
+			return pubsub.Ack
+		default:
+			fmt.Println("error: unknown war outcome")
+			return pubsub.NackDiscard
+		}
+	}
+}
+
+func handlerWarResult(gs *gamelogic.GameState, opts Options) func(gamelogic.WarReport) pubsub.AckType {
+	return func(report gamelogic.WarReport) pubsub.AckType {
+		defer opts.afterHandle()
+
+		// Every player is sent every result, defender or not, so each is
+		// acked rather than dead-lettered
+		gs.HandleWarResult(report)
+		return pubsub.Ack
+	}
+}
+
+func handlerControl(gs *gamelogic.GameState, opts Options) func(gamelogic.ControlChange) pubsub.AckType {
+	return func(change gamelogic.ControlChange) pubsub.AckType {
+		defer opts.afterHandle()
+
+		gs.HandleControl(change)
+		return pubsub.Ack
+	}
+}
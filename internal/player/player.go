@@ -0,0 +1,255 @@
+package player
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/gamelogic"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/pubsub"
+	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ErrLoginRejected means the server answered but refused the credentials
+var ErrLoginRejected = errors.New("login rejected")
+
+// Options customise how a player reacts to the messages it receives
+type Options struct {
+	// OnMove sees every move broadcast before it is handled, including the
+	// player's own
+	OnMove func(gamelogic.ArmyMove)
+	// AfterHandle runs once each message has been handled. The client uses
+	// it to redraw its prompt.
+	AfterHandle func()
+}
+
+func (o Options) afterHandle() {
+	if o.AfterHandle != nil {
+		o.AfterHandle()
+	}
+}
+
+// Login asks the server for a session token
+func Login(conn *amqp.Connection, username, password string) (string, error) {
+	resp, err := pubsub.Call[routing.LoginRequest, routing.LoginResponse](
+		conn,
+		routing.ExchangePerilTopic,
+		routing.LoginKey,
+		pubsub.JSONCodec{},
+		routing.LoginRequest{Username: username, Password: password},
+		pubsub.DefaultRPCTimeout,
+	)
+	if errors.Is(err, pubsub.ErrRPCTimeout) {
+		return "", errors.New("the server did not answer, is it running?")
+	}
+	if err != nil {
+		return "", err
+	}
+	if resp.Error != "" {
+		return "", fmt.Errorf("%w: %s", ErrLoginRejected, resp.Error)
+	}
+	return resp.Token, nil
+}
+
+func Logout(conn *amqp.Connection, username, token string) error {
+	resp, err := pubsub.Call[routing.LogoutRequest, routing.LogoutResponse](
+		conn,
+		routing.ExchangePerilTopic,
+		routing.LogoutKey,
+		pubsub.JSONCodec{},
+		routing.LogoutRequest{Username: username, Token: token},
+		pubsub.DefaultRPCTimeout,
+	)
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+// FetchMap asks the server which map the match is played on
+func FetchMap(conn *amqp.Connection) (*gamelogic.Map, error) {
+	resp, err := pubsub.Call[routing.MapRequest, routing.MapResponse](
+		conn,
+		routing.ExchangePerilTopic,
+		routing.MapKey,
+		pubsub.JSONCodec{},
+		routing.MapRequest{},
+		pubsub.DefaultRPCTimeout,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch the map: %w", err)
+	}
+	return gamelogic.ParseMap(resp.Definition)
+}
+
+// FetchArmy asks the server for username's army as its board has it.
+// Seeding the game with it lets a player that lost its saved game spawn.
+func FetchArmy(conn *amqp.Connection, username, token string) (gamelogic.Player, error) {
+	resp, err := pubsub.Call[gamelogic.ArmyRequest, gamelogic.ArmyResponse](
+		conn,
+		routing.ExchangePerilTopic,
+		routing.ArmyKey,
+		pubsub.JSONCodec{},
+		gamelogic.ArmyRequest{Username: username, Token: token},
+		pubsub.DefaultRPCTimeout,
+	)
+	if err != nil {
+		return gamelogic.Player{}, fmt.Errorf("failed to fetch the army: %w", err)
+	}
+	if resp.Error != "" {
+		return gamelogic.Player{}, fmt.Errorf("failed to fetch the army: %s", resp.Error)
+	}
+	return resp.Army, nil
+}
+
+// ErrOrderRejected is returned by SubmitOrder when the server refuses an
+// order, such as one that arrives after its turn is over
+var ErrOrderRejected = errors.New("order rejected")
+
+// SubmitOrder sends move to the server to be carried out when turn ends
+func SubmitOrder(conn *amqp.Connection, username, token string, turn int, move gamelogic.ArmyMove) error {
+	resp, err := pubsub.Call[gamelogic.TurnOrder, gamelogic.TurnOrderResponse](
+		conn,
+		routing.ExchangePerilTopic,
+		routing.TurnOrderKey,
+		pubsub.JSONCodec{},
+		gamelogic.TurnOrder{Username: username, Token: token, Turn: turn, Move: move},
+		pubsub.DefaultRPCTimeout,
+	)
+	if errors.Is(err, pubsub.ErrRPCTimeout) {
+		return errors.New("the server did not answer, is it running with turns on?")
+	}
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return fmt.Errorf("%w: %s", ErrOrderRejected, resp.Error)
+	}
+	return nil
+}
+
+// SpawnApprover has the server put each unit on its board, and charge for
+// it with the economy on, before it is spawned. Set it as
+// GameState.ApproveSpawn.
+func SpawnApprover(conn *amqp.Connection, gs *gamelogic.GameState, token string) func(gamelogic.Unit) error {
+	return func(unit gamelogic.Unit) error {
+		balance, err := RequestSpawn(conn, gs.GetUsername(), token, unit)
+		if err != nil {
+			return err
+		}
+		gs.SetBalance(balance)
+		return nil
+	}
+}
+
+// RequestSpawn asks the server to put unit on its board for username, and
+// returns the balance left after paying for it
+func RequestSpawn(conn *amqp.Connection, username, token string, unit gamelogic.Unit) (gamelogic.Balance, error) {
+	resp, err := pubsub.Call[gamelogic.SpawnRequest, gamelogic.SpawnResponse](
+		conn,
+		routing.ExchangePerilTopic,
+		routing.SpawnKey,
+		pubsub.JSONCodec{},
+		gamelogic.SpawnRequest{Username: username, Token: token, Unit: unit},
+		pubsub.DefaultRPCTimeout,
+	)
+	if errors.Is(err, pubsub.ErrRPCTimeout) {
+		return gamelogic.Balance{}, errors.New("the server did not answer, is it running?")
+	}
+	if err != nil {
+		return gamelogic.Balance{}, err
+	}
+	if resp.Error != "" {
+		return resp.Balance, errors.New(resp.Error)
+	}
+	return resp.Balance, nil
+}
+
+// SessionHeaders carry the session token. Messages the server consumes
+// carry it so the server can tell them apart from impersonators.
+func SessionHeaders(token string) amqp.Table {
+	return amqp.Table{routing.SessionTokenHeader: token}
+}
+
+// LogCodec encodes game logs with the session token attached
+func LogCodec(token string) pubsub.Codec {
+	return pubsub.WithHeaders(pubsub.GobCodec{}, SessionHeaders(token))
+}
+
+// SessionCodec encodes moves and wars with the session token attached. The
+// server checks them before relaying them to the other players.
+func SessionCodec(token string) pubsub.Codec {
+	return pubsub.WithHeaders(pubsub.JSONCodec{}, SessionHeaders(token))
+}
+
+// MoveKey is the routing key a player's moves are published with
+func MoveKey(username string) string {
+	return fmt.Sprintf("%s.%s", routing.ArmyMovesPrefix, username)
+}
+
+// Join declares the player's queues and subscribes gs to pauses, map
+// changes, moves, wars and territory control. War results go out through
+// publisher. Moves, wars, war results and control changes are only taken
+// from the server, and what this player sends carries token so the server
+// accepts it.
+func Join(conn *amqp.Connection, gs *gamelogic.GameState, publisher pubsub.Sender, token string, opts Options) error {
+	username := gs.GetUsername()
+	codec := SessionCodec(token)
+
+	pauseQueue := fmt.Sprintf("%s.%s", routing.PauseKey, username)
+	err := pubsub.SubscribeJSON(conn, routing.ExchangePerilDirect, pauseQueue, routing.PauseKey, pubsub.Transient, handlerPause(gs, opts))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", pauseQueue, err)
+	}
+
+	mapQueue := fmt.Sprintf("%s.%s", routing.MapChangedKey, username)
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilDirect, mapQueue, routing.MapChangedKey, pubsub.Transient, handlerMapChanged(gs, opts))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", mapQueue, err)
+	}
+
+	turnQueue := fmt.Sprintf("%s.%s", routing.TurnKey, username)
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilDirect, turnQueue, routing.TurnKey, pubsub.Transient, handlerTurn(gs, opts))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", turnQueue, err)
+	}
+
+	economyQueue := fmt.Sprintf("%s.%s", routing.EconomyKey, username)
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilDirect, economyQueue, routing.EconomyKey, pubsub.Transient, handlerEconomy(gs))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", economyQueue, err)
+	}
+
+	movesQueue := MoveKey(username)
+	movesKey := routing.VerifiedKey(fmt.Sprintf("%s.*", routing.ArmyMovesPrefix))
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilTopic, movesQueue, movesKey, pubsub.Transient, handlerMove(gs, opts))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", movesQueue, err)
+	}
+
+	resultsQueue := fmt.Sprintf("%s.%s", routing.WarResultsPrefix, username)
+	resultsKey := routing.VerifiedKey(fmt.Sprintf("%s.*", routing.WarResultsPrefix))
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilTopic, resultsQueue, resultsKey, pubsub.Durable, handlerWarResult(gs, opts))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", resultsQueue, err)
+	}
+
+	territoryQueue := fmt.Sprintf("%s.%s", routing.TerritoryPrefix, username)
+	territoryKey := routing.VerifiedKey(fmt.Sprintf("%s.*", routing.TerritoryPrefix))
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilTopic, territoryQueue, territoryKey, pubsub.Transient, handlerControl(gs, opts))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", territoryQueue, err)
+	}
+
+	// Wars are routed to the attacker that resolves them, and wait in a
+	// durable queue while it is away
+	warQueue := fmt.Sprintf("%s.%s", routing.WarRecognitionsPrefix, username)
+	err = pubsub.SubscribeJSON(conn, routing.ExchangePerilTopic, warQueue, routing.VerifiedKey(warQueue), pubsub.Durable, handlerWar(gs, publisher, codec, LogCodec(token), opts))
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", warQueue, err)
+	}
+	return nil
+}
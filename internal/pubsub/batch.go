@@ -0,0 +1,103 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DefaultBatchWindow caps how many messages of a batch wait for
+// confirmation at once
+const DefaultBatchWindow = 256
+
+// Batch collects encoded messages to publish together
+type Batch struct {
+	msgs []batchMessage
+}
+
+type batchMessage struct {
+	exchange string
+	key      string
+	msg      amqp.Publishing
+}
+
+// Add encodes val with codec and appends it to the batch
+func (b *Batch) Add(exchange, key string, codec Codec, val any) error {
+	var msg amqp.Publishing
+	if err := codec.Marshal(val, &msg); err != nil {
+		return err
+	}
+	b.msgs = append(b.msgs, batchMessage{exchange: exchange, key: key, msg: msg})
+	return nil
+}
+
+func (b *Batch) Len() int {
+	return len(b.msgs)
+}
+
+// Confirmation resolves once the broker acks or nacks a message
+type Confirmation interface {
+	WaitContext(ctx context.Context) (bool, error)
+}
+
+// DeferredPublisher publishes a message without waiting for the broker's
+// confirmation. *Publisher is one.
+type DeferredPublisher interface {
+	PublishDeferred(ctx context.Context, exchange, key string, msg amqp.Publishing) (Confirmation, error)
+}
+
+// PublishBatch pipelines every message in b through a confirming publisher
+// and returns one result per message, in the order they were added: nil if
+// the broker confirmed it, otherwise why it was not.
+func PublishBatch(ctx context.Context, p DeferredPublisher, b *Batch) []error {
+	results := make([]error, len(b.msgs))
+	inFlight := make([]Confirmation, len(b.msgs))
+
+	wait := func(i int) {
+		if inFlight[i] == nil {
+			return
+		}
+		acked, err := inFlight[i].WaitContext(ctx)
+		switch {
+		case err != nil:
+			results[i] = fmt.Errorf("failed waiting for confirmation: %w", err)
+		case !acked:
+			results[i] = ErrNacked
+		}
+		inFlight[i] = nil
+	}
+
+	for i, bm := range b.msgs {
+		if i >= DefaultBatchWindow {
+			wait(i - DefaultBatchWindow)
+		}
+		confirm, err := p.PublishDeferred(ctx, bm.exchange, bm.key, bm.msg)
+		if err != nil {
+			results[i] = err
+			continue
+		}
+		inFlight[i] = confirm
+	}
+
+	for i := range b.msgs {
+		wait(i)
+	}
+	return results
+}
+
+// CountFailed returns how many results are errors and the first of them
+func CountFailed(results []error) (int, error) {
+	failed := 0
+	var first error
+	for _, err := range results {
+		if err == nil {
+			continue
+		}
+		if first == nil {
+			first = err
+		}
+		failed++
+	}
+	return failed, first
+}
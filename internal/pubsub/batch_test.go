@@ -0,0 +1,134 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// fakeConfirmation answers with acked, or blocks until the context is
+// done when hang is set. It tells its publisher when it has been waited on.
+type fakeConfirmation struct {
+	acked  bool
+	hang   bool
+	waited func()
+}
+
+func (f fakeConfirmation) WaitContext(ctx context.Context) (bool, error) {
+	f.waited()
+	if f.hang {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	return f.acked, nil
+}
+
+// fakeDeferred answers each message by its key: "nack" is nacked, "fail"
+// cannot be published, "hang" is never confirmed and anything else is
+// acked. It records the keys in publish order and the most messages in
+// flight at once.
+type fakeDeferred struct {
+	keys        []string
+	inFlight    int
+	maxInFlight int
+}
+
+func (f *fakeDeferred) PublishDeferred(_ context.Context, _, key string, _ amqp.Publishing) (Confirmation, error) {
+	f.keys = append(f.keys, key)
+	if key == "fail" {
+		return nil, errors.New("channel closed")
+	}
+	f.inFlight++
+	f.maxInFlight = max(f.maxInFlight, f.inFlight)
+	return fakeConfirmation{
+		acked:  key != "nack",
+		hang:   key == "hang",
+		waited: func() { f.inFlight-- },
+	}, nil
+}
+
+func newBatch(t *testing.T, keys ...string) *Batch {
+	t.Helper()
+	b := &Batch{}
+	for _, key := range keys {
+		if err := b.Add("peril_topic", key, JSONCodec{}, key); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	return b
+}
+
+func TestPublishBatchResults(t *testing.T) {
+	keys := []string{"a", "nack", "b", "fail", "nack", "c"}
+	p := &fakeDeferred{}
+	results := PublishBatch(context.Background(), p, newBatch(t, keys...))
+
+	if !reflect.DeepEqual(p.keys, keys) {
+		t.Fatalf("published %v, want %v", p.keys, keys)
+	}
+	if len(results) != len(keys) {
+		t.Fatalf("%d results for %d messages", len(results), len(keys))
+	}
+	// Each result belongs to the message at the same index
+	for i, key := range keys {
+		err := results[i]
+		switch key {
+		case "nack":
+			if !errors.Is(err, ErrNacked) {
+				t.Errorf("result %d (%s): %v, want ErrNacked", i, key, err)
+			}
+		case "fail":
+			if err == nil || errors.Is(err, ErrNacked) {
+				t.Errorf("result %d (%s): %v, want the publish error", i, key, err)
+			}
+		default:
+			if err != nil {
+				t.Errorf("result %d (%s): %v", i, key, err)
+			}
+		}
+	}
+
+	failed, first := CountFailed(results)
+	if failed != 3 || !errors.Is(first, ErrNacked) {
+		t.Fatalf("CountFailed = %d, %v, want 3 failures starting with ErrNacked", failed, first)
+	}
+}
+
+func TestPublishBatchWindow(t *testing.T) {
+	keys := make([]string, DefaultBatchWindow*2+10)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	keys[DefaultBatchWindow+3] = "nack"
+	p := &fakeDeferred{}
+	results := PublishBatch(context.Background(), p, newBatch(t, keys...))
+
+	if p.maxInFlight != DefaultBatchWindow {
+		t.Fatalf("%d messages in flight at once, want %d", p.maxInFlight, DefaultBatchWindow)
+	}
+	if p.inFlight != 0 {
+		t.Fatalf("%d confirmations never waited for", p.inFlight)
+	}
+	failed, _ := CountFailed(results)
+	if failed != 1 || !errors.Is(results[DefaultBatchWindow+3], ErrNacked) {
+		t.Fatalf("%d failed, result %d is %v, want only it nacked", failed, DefaultBatchWindow+3, results[DefaultBatchWindow+3])
+	}
+}
+
+func TestPublishBatchTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	results := PublishBatch(ctx, &fakeDeferred{}, newBatch(t, "a", "hang", "b"))
+
+	if results[0] != nil || results[2] != nil {
+		t.Fatalf("confirmed messages failed: %v", results)
+	}
+	if !errors.Is(results[1], context.DeadlineExceeded) {
+		t.Fatalf("unconfirmed message: %v, want the deadline", results[1])
+	}
+}
@@ -0,0 +1,125 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeGob  = "application/gob"
+
+	// SentAtHeader carries the send time in Unix nanoseconds, since the
+	// AMQP timestamp property only has second precision
+	SentAtHeader = "x-peril-sent-at"
+)
+
+// Codec turns values into message bodies and back. Codecs may also set
+// headers on the outgoing message, which lets wrappers such as the
+// encrypting codec carry metadata alongside the payload.
+type Codec interface {
+	Marshal(val any, msg *amqp.Publishing) error
+	Unmarshal(msg *amqp.Delivery, val any) error
+}
+
+// JSONCodec encodes values as JSON
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(val any, msg *amqp.Publishing) error {
+	body, err := json.Marshal(val)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+	msg.ContentType = ContentTypeJSON
+	msg.Body = body
+	return nil
+}
+
+func (JSONCodec) Unmarshal(msg *amqp.Delivery, val any) error {
+	return json.Unmarshal(msg.Body, val)
+}
+
+// GobCodec encodes values with encoding/gob
+type GobCodec struct{}
+
+func (GobCodec) Marshal(val any, msg *amqp.Publishing) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(val); err != nil {
+		return fmt.Errorf("failed to gob encode value: %w", err)
+	}
+	msg.ContentType = ContentTypeGob
+	msg.Body = buf.Bytes()
+	return nil
+}
+
+func (GobCodec) Unmarshal(msg *amqp.Delivery, val any) error {
+	return gob.NewDecoder(bytes.NewReader(msg.Body)).Decode(val)
+}
+
+type headerCodec struct {
+	inner   Codec
+	headers amqp.Table
+}
+
+// WithHeaders wraps codec so every message it marshals carries headers
+func WithHeaders(codec Codec, headers amqp.Table) Codec {
+	return headerCodec{inner: codec, headers: headers}
+}
+
+func (c headerCodec) Marshal(val any, msg *amqp.Publishing) error {
+	if err := c.inner.Marshal(val, msg); err != nil {
+		return err
+	}
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	for k, v := range c.headers {
+		msg.Headers[k] = v
+	}
+	return nil
+}
+
+func (c headerCodec) Unmarshal(msg *amqp.Delivery, val any) error {
+	return c.inner.Unmarshal(msg, val)
+}
+
+type timestampCodec struct {
+	inner Codec
+}
+
+// WithTimestamp wraps codec so every message it marshals is stamped with
+// the time it was encoded, see SentAt
+func WithTimestamp(codec Codec) Codec {
+	return timestampCodec{inner: codec}
+}
+
+func (c timestampCodec) Marshal(val any, msg *amqp.Publishing) error {
+	if err := c.inner.Marshal(val, msg); err != nil {
+		return err
+	}
+	now := time.Now()
+	if msg.Headers == nil {
+		msg.Headers = amqp.Table{}
+	}
+	msg.Timestamp = now
+	msg.Headers[SentAtHeader] = now.UnixNano()
+	return nil
+}
+
+func (c timestampCodec) Unmarshal(msg *amqp.Delivery, val any) error {
+	return c.inner.Unmarshal(msg, val)
+}
+
+// SentAt reads the send time stamped by WithTimestamp
+func SentAt(msg *amqp.Delivery) (time.Time, bool) {
+	nanos, ok := msg.Headers[SentAtHeader].(int64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
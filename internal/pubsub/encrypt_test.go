@@ -0,0 +1,155 @@
+package pubsub
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+type secretMessage struct {
+	From string
+	Text string
+}
+
+var (
+	testKeyA = bytes.Repeat([]byte{0xa}, 32)
+	testKeyB = bytes.Repeat([]byte{0xb}, 16)
+)
+
+func seal(t *testing.T, codec Codec, val any) *amqp.Delivery {
+	t.Helper()
+	var msg amqp.Publishing
+	if err := codec.Marshal(val, &msg); err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	return &amqp.Delivery{Headers: msg.Headers, ContentType: msg.ContentType, ContentEncoding: msg.ContentEncoding, Body: msg.Body}
+}
+
+func TestEncryptedCodecRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		inner    Codec
+		sealWith string
+		openWith string
+	}{
+		{"json", JSONCodec{}, "a", "a"},
+		{"gob", GobCodec{}, "a", "a"},
+		{"rotated key", JSONCodec{}, "a", "b"},
+		{"short key", JSONCodec{}, "b", "a"},
+	}
+	keys := map[string][]byte{"a": testKeyA, "b": testKeyB}
+	want := secretMessage{From: "alice", Text: "attack at dawn"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sealer, err := NewEncryptedCodec(tt.inner, tt.sealWith, keys)
+			if err != nil {
+				t.Fatalf("NewEncryptedCodec: %v", err)
+			}
+			opener, err := NewEncryptedCodec(tt.inner, tt.openWith, keys)
+			if err != nil {
+				t.Fatalf("NewEncryptedCodec: %v", err)
+			}
+
+			msg := seal(t, sealer, want)
+			if bytes.Contains(msg.Body, []byte(want.Text)) {
+				t.Fatal("body contains the plaintext")
+			}
+			if msg.Headers[KeyIDHeader] != tt.sealWith {
+				t.Fatalf("key ID header = %v, want %q", msg.Headers[KeyIDHeader], tt.sealWith)
+			}
+
+			var got secretMessage
+			if err := opener.Unmarshal(msg, &got); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if got != want {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestEncryptedCodecRejectsTampering(t *testing.T) {
+	codec, err := NewEncryptedCodec(JSONCodec{}, "a", map[string][]byte{"a": testKeyA, "b": testKeyB})
+	if err != nil {
+		t.Fatalf("NewEncryptedCodec: %v", err)
+	}
+	onlyB, err := NewEncryptedCodec(JSONCodec{}, "b", map[string][]byte{"b": testKeyB})
+	if err != nil {
+		t.Fatalf("NewEncryptedCodec: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		opener *EncryptedCodec
+		tamper func(msg *amqp.Delivery)
+	}{
+		{"flipped body bit", codec, func(msg *amqp.Delivery) { msg.Body[len(msg.Body)-1] ^= 1 }},
+		{"swapped key ID", codec, func(msg *amqp.Delivery) { msg.Headers[KeyIDHeader] = "b" }},
+		{"missing key ID", codec, func(msg *amqp.Delivery) { delete(msg.Headers, KeyIDHeader) }},
+		{"not encrypted", codec, func(msg *amqp.Delivery) { msg.ContentEncoding = "" }},
+		{"truncated", codec, func(msg *amqp.Delivery) { msg.Body = msg.Body[:4] }},
+		{"unknown key", onlyB, func(msg *amqp.Delivery) {}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := seal(t, codec, secretMessage{From: "alice", Text: "hi"})
+			tt.tamper(msg)
+
+			var got secretMessage
+			err := tt.opener.Unmarshal(msg, &got)
+			if !errors.Is(err, ErrDecrypt) {
+				t.Fatalf("Unmarshal error = %v, want ErrDecrypt", err)
+			}
+			if got != (secretMessage{}) {
+				t.Fatalf("tampered message was decoded into %+v", got)
+			}
+		})
+	}
+}
+
+func TestNewEncryptedCodecRejectsBadKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		activeID string
+		keys     map[string][]byte
+	}{
+		{"missing active key", "c", map[string][]byte{"a": testKeyA}},
+		{"bad key length", "a", map[string][]byte{"a": []byte("short")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewEncryptedCodec(JSONCodec{}, tt.activeID, tt.keys); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		in      string
+		wantLen int
+		wantErr bool
+	}{
+		{"000102030405060708090a0b0c0d0e0f", 16, false},
+		{"000102030405060708090a0b0c0d0e0f0001020304050607", 24, false},
+		{"000102030405060708090a0b0c0d0e0f000102030405060708090a0b0c0d0e0f", 32, false},
+		{"0001", 0, true},
+		{"not hex", 0, true},
+	}
+	for _, tt := range tests {
+		key, err := ParseKey(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseKey(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if len(key) != tt.wantLen {
+			t.Errorf("ParseKey(%q) returned %d bytes, want %d", tt.in, len(key), tt.wantLen)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package pubsub
+
+import (
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Filter inspects a delivery before it is decoded. Returning anything other
+// than Ack settles the delivery with that AckType and skips the handler.
+type Filter func(msg *amqp.Delivery) AckType
+
+type SubscribeOption func(*subscribeOptions)
+
+type subscribeOptions struct {
+	filters []Filter
+}
+
+// WithFilter runs f on every delivery before the handler sees it
+func WithFilter(f Filter) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.filters = append(o.filters, f)
+	}
+}
+
+func (o subscribeOptions) filter(msg *amqp.Delivery) AckType {
+	for _, f := range o.filters {
+		if ackType := f(msg); ackType != Ack {
+			return ackType
+		}
+	}
+	return Ack
+}
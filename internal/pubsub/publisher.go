@@ -0,0 +1,286 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Sender is anything messages can be published through, such as an
+// *amqp.Channel or a *Publisher
+type Sender interface {
+	PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error
+}
+
+// BlockedPolicy decides what a Publisher does while the broker is pushing back
+type BlockedPolicy int
+
+const (
+	// FailFast returns ErrBlocked instead of publishing
+	FailFast BlockedPolicy = iota
+	// BufferWhenBlocked keeps messages in memory until the broker unblocks
+	BufferWhenBlocked
+)
+
+const DefaultPublishBufferSize = 100
+
+var (
+	ErrBlocked    = errors.New("broker is blocking publishes")
+	ErrBufferFull = errors.New("publish buffer is full")
+	ErrNacked     = errors.New("broker rejected message")
+	ErrNoConfirm  = errors.New("publisher is not in confirm mode")
+)
+
+type PublisherConfig struct {
+	Policy BlockedPolicy
+	// BufferSize caps how many messages BufferWhenBlocked holds
+	BufferSize int
+	// OnBlocked is called whenever the blocked state changes
+	OnBlocked func(blocked bool, reason string)
+	// Confirm puts the channel in confirm mode so the broker acknowledges
+	// every message it takes responsibility for
+	Confirm bool
+	// OnFailed is called with each buffered message that could not be
+	// published once the broker unblocked. Without it failures are printed.
+	OnFailed func(exchange, key string, err error)
+}
+
+type pendingPublish struct {
+	exchange  string
+	key       string
+	mandatory bool
+	immediate bool
+	msg       amqp.Publishing
+}
+
+// Publisher publishes on its own channel and watches for the broker's flow
+// control: connection.blocked, raised on memory or disk alarms, and
+// channel.flow. While either is in effect it fails fast or buffers
+// according to its policy rather than stalling the caller. mu only guards
+// the state below; publishing itself happens outside it, so Blocked and
+// Pending answer even while the broker is slow to take a message.
+type Publisher struct {
+	ch *amqp.Channel
+	// out is where messages go, ch outside of tests
+	out     Sender
+	cfg     PublisherConfig
+	mu      *sync.Mutex
+	blocked bool
+	flowOff bool
+	reason  string
+	buffer  []pendingPublish
+	// flushing is set while the backlog is being published. New messages
+	// join the buffer meanwhile so they cannot overtake it.
+	flushing bool
+}
+
+func NewPublisher(conn *amqp.Connection, cfg PublisherConfig) (*Publisher, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	if cfg.Confirm {
+		if err := ch.Confirm(false); err != nil {
+			ch.Close()
+			return nil, fmt.Errorf("failed to enable publisher confirms: %w", err)
+		}
+	}
+
+	p := newPublisher(ch, cfg)
+	p.ch = ch
+	blockings := conn.NotifyBlocked(make(chan amqp.Blocking, 1))
+	flows := ch.NotifyFlow(make(chan bool, 1))
+	go p.watch(blockings, flows)
+
+	return p, nil
+}
+
+// newPublisher publishes through out. Its caller starts watch.
+func newPublisher(out Sender, cfg PublisherConfig) *Publisher {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultPublishBufferSize
+	}
+	return &Publisher{
+		out: out,
+		cfg: cfg,
+		mu:  &sync.Mutex{},
+	}
+}
+
+func (p *Publisher) watch(blockings <-chan amqp.Blocking, flows <-chan bool) {
+	for blockings != nil || flows != nil {
+		select {
+		case b, ok := <-blockings:
+			if !ok {
+				blockings = nil
+				continue
+			}
+			p.setState(func() {
+				p.blocked = b.Active
+				p.reason = b.Reason
+			})
+		case active, ok := <-flows:
+			if !ok {
+				flows = nil
+				continue
+			}
+			p.setState(func() {
+				p.flowOff = !active
+			})
+		}
+	}
+}
+
+func (p *Publisher) setState(update func()) {
+	p.mu.Lock()
+	wasBlocked := p.isBlocked()
+	update()
+	blocked := p.isBlocked()
+	startFlush := wasBlocked && !blocked && len(p.buffer) > 0 && !p.flushing
+	if startFlush {
+		p.flushing = true
+	}
+	reason := p.reasonLocked()
+	p.mu.Unlock()
+
+	if startFlush {
+		go p.flush()
+	}
+	if wasBlocked != blocked && p.cfg.OnBlocked != nil {
+		p.cfg.OnBlocked(blocked, reason)
+	}
+}
+
+// flush publishes everything buffered while blocked, oldest first. It runs
+// on its own goroutine so watch keeps hearing from the broker meanwhile,
+// and checks before each message whether the broker has blocked again, in
+// which case it stops and leaves the rest for the next flush.
+func (p *Publisher) flush() {
+	for {
+		p.mu.Lock()
+		if len(p.buffer) == 0 || p.isBlocked() {
+			p.flushing = false
+			p.mu.Unlock()
+			return
+		}
+		pp := p.buffer[0]
+		p.buffer = p.buffer[1:]
+		p.mu.Unlock()
+
+		err := p.out.PublishWithContext(context.Background(), pp.exchange, pp.key, pp.mandatory, pp.immediate, pp.msg)
+		if err != nil {
+			p.failed(pp, err)
+		}
+	}
+}
+
+// failed reports a buffered message that could not be published
+func (p *Publisher) failed(pp pendingPublish, err error) {
+	if p.cfg.OnFailed != nil {
+		p.cfg.OnFailed(pp.exchange, pp.key, err)
+		return
+	}
+	fmt.Printf("failed to publish buffered message to %s: %v\n", pp.key, err)
+}
+
+func (p *Publisher) isBlocked() bool {
+	return p.blocked || p.flowOff
+}
+
+func (p *Publisher) reasonLocked() string {
+	if p.blocked {
+		return p.reason
+	}
+	if p.flowOff {
+		return "channel flow paused"
+	}
+	return ""
+}
+
+// Blocked reports whether the broker is currently pushing back, and why
+func (p *Publisher) Blocked() (bool, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.isBlocked(), p.reasonLocked()
+}
+
+// Pending is the number of buffered messages waiting for the broker
+func (p *Publisher) Pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.buffer)
+}
+
+func (p *Publisher) PublishWithContext(ctx context.Context, exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	p.mu.Lock()
+	if p.isBlocked() || p.flushing {
+		defer p.mu.Unlock()
+		if p.isBlocked() && p.cfg.Policy == FailFast {
+			return fmt.Errorf("%w: %s", ErrBlocked, p.reasonLocked())
+		}
+		if len(p.buffer) >= p.cfg.BufferSize {
+			return fmt.Errorf("%w: %d messages waiting", ErrBufferFull, len(p.buffer))
+		}
+		p.buffer = append(p.buffer, pendingPublish{
+			exchange:  exchange,
+			key:       key,
+			mandatory: mandatory,
+			immediate: immediate,
+			msg:       msg,
+		})
+		return nil
+	}
+	p.mu.Unlock()
+
+	return p.out.PublishWithContext(ctx, exchange, key, mandatory, immediate, msg)
+}
+
+// PublishDeferred publishes without waiting for the broker's confirmation.
+// The returned confirmation resolves once the broker acks or nacks the
+// message, so many messages can be in flight at once. Confirmed publishes
+// are never buffered; they fail fast while the broker is blocked.
+func (p *Publisher) PublishDeferred(ctx context.Context, exchange, key string, msg amqp.Publishing) (Confirmation, error) {
+	if !p.cfg.Confirm {
+		return nil, ErrNoConfirm
+	}
+
+	p.mu.Lock()
+	blocked, reason := p.isBlocked(), p.reasonLocked()
+	p.mu.Unlock()
+	if blocked {
+		return nil, fmt.Errorf("%w: %s", ErrBlocked, reason)
+	}
+
+	confirm, err := p.ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish message: %w", err)
+	}
+	if confirm == nil {
+		return nil, ErrNoConfirm
+	}
+	return confirm, nil
+}
+
+// PublishConfirmed publishes and waits until the broker confirms the message
+func (p *Publisher) PublishConfirmed(ctx context.Context, exchange, key string, msg amqp.Publishing) error {
+	confirm, err := p.PublishDeferred(ctx, exchange, key, msg)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed waiting for confirmation: %w", err)
+	}
+	if !acked {
+		return ErrNacked
+	}
+	return nil
+}
+
+func (p *Publisher) Close() error {
+	return p.ch.Close()
+}
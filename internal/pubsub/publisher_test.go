@@ -0,0 +1,198 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// gatedSender records the keys it publishes. With a gate, every publish
+// waits for a value on it first, like a broker slow to take a message.
+type gatedSender struct {
+	mu   *sync.Mutex
+	keys []string
+	gate chan struct{}
+	fail string
+}
+
+func newGatedSender() *gatedSender {
+	return &gatedSender{mu: &sync.Mutex{}}
+}
+
+func (g *gatedSender) PublishWithContext(_ context.Context, _, key string, _, _ bool, _ amqp.Publishing) error {
+	g.mu.Lock()
+	gate := g.gate
+	g.mu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+	if key == g.fail {
+		return errors.New("channel closed")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.keys = append(g.keys, key)
+	return nil
+}
+
+func (g *gatedSender) published() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string{}, g.keys...)
+}
+
+// watched starts p watching unbuffered notification channels, so a send
+// on them only completes once watch has taken it
+func watched(p *Publisher) (chan amqp.Blocking, chan bool) {
+	blockings := make(chan amqp.Blocking)
+	flows := make(chan bool)
+	go p.watch(blockings, flows)
+	return blockings, flows
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func publish(p *Publisher, key string) error {
+	return p.PublishWithContext(context.Background(), "peril_topic", key, false, false, amqp.Publishing{})
+}
+
+func TestPublisherFailFast(t *testing.T) {
+	out := newGatedSender()
+	changes := make(chan bool, 4)
+	p := newPublisher(out, PublisherConfig{
+		Policy:    FailFast,
+		OnBlocked: func(blocked bool, _ string) { changes <- blocked },
+	})
+	blockings, flows := watched(p)
+
+	blockings <- amqp.Blocking{Active: true, Reason: "low on memory"}
+	if blocked := <-changes; !blocked {
+		t.Fatal("OnBlocked did not report the block")
+	}
+	if err := publish(p, "a"); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("publish while blocked: %v, want ErrBlocked", err)
+	}
+
+	// Channel flow holds publishes back too, until both have cleared
+	flows <- false
+	blockings <- amqp.Blocking{Active: false}
+	if err := publish(p, "b"); !errors.Is(err, ErrBlocked) {
+		t.Fatalf("publish with flow off: %v, want ErrBlocked", err)
+	}
+	flows <- true
+	if blocked := <-changes; blocked {
+		t.Fatal("OnBlocked did not report the unblock")
+	}
+	if err := publish(p, "c"); err != nil {
+		t.Fatalf("publish after unblocking: %v", err)
+	}
+	if got := out.published(); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Fatalf("published %v, want [c]", got)
+	}
+	if p.Pending() != 0 {
+		t.Fatalf("FailFast buffered %d messages", p.Pending())
+	}
+}
+
+func TestPublisherBufferWhenBlocked(t *testing.T) {
+	out := newGatedSender()
+	out.fail = "bad"
+	failed := make(chan string, 1)
+	p := newPublisher(out, PublisherConfig{
+		Policy:     BufferWhenBlocked,
+		BufferSize: 3,
+		OnFailed:   func(_, key string, _ error) { failed <- key },
+	})
+	blockings, _ := watched(p)
+
+	blockings <- amqp.Blocking{Active: true, Reason: "low on disk"}
+	for _, key := range []string{"a", "bad", "b"} {
+		if err := publish(p, key); err != nil {
+			t.Fatalf("publish %s while blocked: %v", key, err)
+		}
+	}
+	if err := publish(p, "c"); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("publish past the buffer: %v, want ErrBufferFull", err)
+	}
+	if len(out.published()) != 0 || p.Pending() != 3 {
+		t.Fatalf("published %v with %d pending while blocked", out.published(), p.Pending())
+	}
+
+	blockings <- amqp.Blocking{Active: false}
+	waitFor(t, "the flush", func() bool { return p.Pending() == 0 && len(out.published()) == 2 })
+	if got := out.published(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("flushed %v, want [a b]", got)
+	}
+	select {
+	case key := <-failed:
+		if key != "bad" {
+			t.Fatalf("OnFailed got %s, want bad", key)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnFailed was not called for the failed message")
+	}
+}
+
+func TestPublisherFlushStopsWhenBlockedAgain(t *testing.T) {
+	out := newGatedSender()
+	p := newPublisher(out, PublisherConfig{Policy: BufferWhenBlocked})
+	blockings, _ := watched(p)
+
+	blockings <- amqp.Blocking{Active: true}
+	for _, key := range []string{"a", "b", "c"} {
+		if err := publish(p, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The broker is slow to take the first message of the flush, and
+	// blocks again meanwhile. watch must still hear about it.
+	out.mu.Lock()
+	out.gate = make(chan struct{})
+	out.mu.Unlock()
+	blockings <- amqp.Blocking{Active: false}
+	waitFor(t, "the flush to take a", func() bool { return p.Pending() == 2 })
+	select {
+	case blockings <- amqp.Blocking{Active: true}:
+	case <-time.After(time.Second):
+		t.Fatal("watch stopped listening while flushing")
+	}
+	waitFor(t, "the block", func() bool { blocked, _ := p.Blocked(); return blocked })
+	close(out.gate)
+
+	waitFor(t, "the flush to stop", func() bool {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return !p.flushing
+	})
+	if got := out.published(); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("published %v while blocked again, want [a]", got)
+	}
+	if p.Pending() != 2 {
+		t.Fatalf("%d pending, want 2", p.Pending())
+	}
+
+	// New messages wait behind the rest of the backlog
+	if err := publish(p, "d"); err != nil {
+		t.Fatal(err)
+	}
+	blockings <- amqp.Blocking{Active: false}
+	waitFor(t, "the second flush", func() bool { return p.Pending() == 0 && len(out.published()) == 4 })
+	if got := out.published(); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Fatalf("published %v, want a to d in order", got)
+	}
+}
@@ -1,10 +1,7 @@
 package pubsub
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
-	"encoding/json"
 	"fmt"
 
 	"github.com/bootdotdev/learn-pub-sub-starter/internal/routing"
@@ -17,6 +14,9 @@ type SimpleQueueType int
 const (
 	Durable SimpleQueueType = iota
 	Transient
+	// DurableSingleActive is a durable queue where the broker delivers to
+	// one consumer at a time and fails over to the next when it goes away
+	DurableSingleActive
 )
 
 const (
@@ -28,6 +28,13 @@ const (
 	QueueClassicQuorum = "quorum"
 )
 
+// Queue settings that can be overridden from configuration at startup
+var (
+	// DurableQueueType is the x-queue-type given to durable queues
+	DurableQueueType = QueueClassicQuorum
+	PrefetchCount    = DefaultPrefetchCount
+)
+
 type AckType int
 
 const (
@@ -36,18 +43,14 @@ const (
 	NackDiscard
 )
 
-func PublishJSON[T any](ch *amqp.Channel, exchange, key string, val T) error {
-	// Marshal the value to JSON
-	body, err := json.Marshal(val)
-	if err != nil {
-		return fmt.Errorf("failed to marshal value: %w", err)
+// Publish encodes val with codec and publishes it to exchange with key
+func Publish[T any](ch Sender, exchange, key string, codec Codec, val T) error {
+	var msg amqp.Publishing
+	if err := codec.Marshal(val, &msg); err != nil {
+		return err
 	}
 
-	// Publish the message
-	err = ch.PublishWithContext(context.Background(), exchange, key, false, false, amqp.Publishing{
-		ContentType: "application/json",
-		Body:        body,
-	})
+	err := ch.PublishWithContext(context.Background(), exchange, key, false, false, msg)
 	if err != nil {
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
@@ -55,16 +58,12 @@ func PublishJSON[T any](ch *amqp.Channel, exchange, key string, val T) error {
 	return nil
 }
 
-func PublishGob[T any](ch *amqp.Channel, exchange, key string, val T) error {
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	if err := enc.Encode(val); err != nil {
-		return fmt.Errorf("failed to gob encode value: %w", err)
-	}
-	return ch.PublishWithContext(context.Background(), exchange, key, false, false, amqp.Publishing{
-		ContentType: "application/gob",
-		Body:        buf.Bytes(),
-	})
+func PublishJSON[T any](ch Sender, exchange, key string, val T) error {
+	return Publish(ch, exchange, key, JSONCodec{}, val)
+}
+
+func PublishGob[T any](ch Sender, exchange, key string, val T) error {
+	return Publish(ch, exchange, key, GobCodec{}, val)
 }
 
 func DeclareAndBind(conn *amqp.Connection, exchange, queueName, key string, simpleQueueType SimpleQueueType) (*amqp.Channel, amqp.Queue, error) {
@@ -75,21 +74,26 @@ func DeclareAndBind(conn *amqp.Connection, exchange, queueName, key string, simp
 	}
 
 	isTransient := simpleQueueType == Transient
-	queueType := QueueClassicQuorum
+	queueType := DurableQueueType
 	if isTransient {
 		queueType = QueueClassicType
 	}
 
+	args := amqp.Table{
+		"x-dead-letter-exchange": routing.ExchangePerilDeadLetter,
+		"x-queue-type":           queueType,
+	}
+	if simpleQueueType == DurableSingleActive {
+		args["x-single-active-consumer"] = true
+	}
+
 	queue, err := chn.QueueDeclare(
 		queueName,
-		simpleQueueType == Durable,
+		!isTransient,
 		isTransient,
 		isTransient,
 		false,
-		amqp.Table{
-			"x-dead-letter-exchange": routing.ExchangePerilDeadLetter,
-			"x-queue-type":           queueType,
-		},
+		args,
 	)
 
 	if err != nil {
@@ -117,16 +121,22 @@ func subscribe[T any](
 	queueName,
 	key string,
 	simpleQueueType SimpleQueueType,
-	handler func(T) AckType,
-	unmarshaller func([]byte) (T, error),
+	handler func(string, T) AckType,
+	codec Codec,
+	opts []SubscribeOption,
 ) error {
+	options := subscribeOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	chn, queue, err := DeclareAndBind(conn, exchange, queueName, key, simpleQueueType)
 	if err != nil {
 		return fmt.Errorf("failed to declare and bind: %w", err)
 	}
 
 	// global is set to false because Quorum queues do not support the true value
-	err = chn.Qos(DefaultPrefetchCount, 0, false)
+	err = chn.Qos(PrefetchCount, 0, false)
 	if err != nil {
 		return fmt.Errorf("failed to set prefetch count: %w", err)
 	}
@@ -147,45 +157,64 @@ func subscribe[T any](
 
 	go func() {
 		for msg := range msgs {
-			val, err := unmarshaller(msg.Body)
-			if err != nil {
-				fmt.Printf("failed to parse message: %v\n", err)
+			if ackType := options.filter(&msg); ackType != Ack {
+				settle(&msg, ackType)
 				continue
 			}
-			ackType := handler(val)
-
-			switch ackType {
-			case Ack:
-				msg.Ack(false)
-				fmt.Printf("acktype: Ack!. \n")
-			case NackRequeue:
-				msg.Nack(false, true)
-				fmt.Printf("acktype: NackRequeue!. \n")
-			case NackDiscard:
+
+			var val T
+			err := codec.Unmarshal(&msg, &val)
+			if err != nil {
+				// Fail closed: a message we cannot decode is never retried
+				fmt.Printf("failed to parse message: %v\n", err)
 				msg.Nack(false, false)
-				fmt.Printf("acktype: NackDiscard!. \n")
-			default:
-				fmt.Printf("Unknown acktype!. \n")
+				continue
 			}
+			settle(&msg, handler(msg.RoutingKey, val))
 		}
 	}()
 
 	return nil
 }
 
-func SubscribeJSON[T any](conn *amqp.Connection, exchange, queueName, key string, simpleQueueType SimpleQueueType, handler func(T) AckType) error {
-	return subscribe(conn, exchange, queueName, key, simpleQueueType, handler, func(data []byte) (T, error) {
-		var val T
-		err := json.Unmarshal(data, &val)
-		return val, err
-	})
+func settle(msg *amqp.Delivery, ackType AckType) {
+	switch ackType {
+	case Ack:
+		msg.Ack(false)
+		fmt.Printf("acktype: Ack!. \n")
+	case NackRequeue:
+		msg.Nack(false, true)
+		fmt.Printf("acktype: NackRequeue!. \n")
+	case NackDiscard:
+		msg.Nack(false, false)
+		fmt.Printf("acktype: NackDiscard!. \n")
+	default:
+		fmt.Printf("Unknown acktype!. \n")
+	}
 }
 
-func SubscribeGob[T any](conn *amqp.Connection, exchange, queueName, key string, simpleQueueType SimpleQueueType, handler func(T) AckType) error {
-	return subscribe(conn, exchange, queueName, key, simpleQueueType, handler, func(data []byte) (T, error) {
-		var val T
-		dec := gob.NewDecoder(bytes.NewReader(data))
-		err := dec.Decode(&val)
-		return val, err
-	})
+// Subscribe declares and binds a queue and hands every delivery, decoded
+// with codec, to handler
+func Subscribe[T any](conn *amqp.Connection, exchange, queueName, key string, simpleQueueType SimpleQueueType, codec Codec, handler func(T) AckType, opts ...SubscribeOption) error {
+	return subscribe(conn, exchange, queueName, key, simpleQueueType, ignoreKey(handler), codec, opts)
+}
+
+// SubscribeWithKey is Subscribe for handlers that need the routing key each
+// message was published with, such as relays that bind a wildcard
+func SubscribeWithKey[T any](conn *amqp.Connection, exchange, queueName, key string, simpleQueueType SimpleQueueType, codec Codec, handler func(string, T) AckType, opts ...SubscribeOption) error {
+	return subscribe(conn, exchange, queueName, key, simpleQueueType, handler, codec, opts)
+}
+
+func SubscribeJSON[T any](conn *amqp.Connection, exchange, queueName, key string, simpleQueueType SimpleQueueType, handler func(T) AckType, opts ...SubscribeOption) error {
+	return subscribe(conn, exchange, queueName, key, simpleQueueType, ignoreKey(handler), JSONCodec{}, opts)
+}
+
+func SubscribeGob[T any](conn *amqp.Connection, exchange, queueName, key string, simpleQueueType SimpleQueueType, handler func(T) AckType, opts ...SubscribeOption) error {
+	return subscribe(conn, exchange, queueName, key, simpleQueueType, ignoreKey(handler), GobCodec{}, opts)
+}
+
+func ignoreKey[T any](handler func(T) AckType) func(string, T) AckType {
+	return func(_ string, val T) AckType {
+		return handler(val)
+	}
 }
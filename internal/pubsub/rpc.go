@@ -0,0 +1,131 @@
+package pubsub
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DirectReplyTo is RabbitMQ's pseudo queue for request/reply without
+// declaring a reply queue per caller
+const DirectReplyTo = "amq.rabbitmq.reply-to"
+
+const DefaultRPCTimeout = 5 * time.Second
+
+var ErrRPCTimeout = errors.New("timed out waiting for reply")
+
+// Call publishes req to exchange with key and waits for a single reply
+func Call[Req, Resp any](conn *amqp.Connection, exchange, key string, codec Codec, req Req, timeout time.Duration) (Resp, error) {
+	var resp Resp
+
+	chn, err := conn.Channel()
+	if err != nil {
+		return resp, fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer chn.Close()
+
+	// The reply consumer must exist before the request is published
+	replies, err := chn.Consume(DirectReplyTo, "", true, false, false, false, nil)
+	if err != nil {
+		return resp, fmt.Errorf("failed to consume replies: %w", err)
+	}
+
+	correlationID, err := newCorrelationID()
+	if err != nil {
+		return resp, err
+	}
+
+	msg := amqp.Publishing{
+		ReplyTo:       DirectReplyTo,
+		CorrelationId: correlationID,
+	}
+	if err := codec.Marshal(req, &msg); err != nil {
+		return resp, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := chn.PublishWithContext(ctx, exchange, key, false, false, msg); err != nil {
+		return resp, fmt.Errorf("failed to publish request: %w", err)
+	}
+
+	for {
+		select {
+		case reply, ok := <-replies:
+			if !ok {
+				return resp, errors.New("reply channel closed")
+			}
+			if reply.CorrelationId != correlationID {
+				continue
+			}
+			if err := codec.Unmarshal(&reply, &resp); err != nil {
+				return resp, fmt.Errorf("failed to parse reply: %w", err)
+			}
+			return resp, nil
+		case <-ctx.Done():
+			return resp, ErrRPCTimeout
+		}
+	}
+}
+
+// Serve answers every request arriving on queueName with handler's response
+func Serve[Req, Resp any](conn *amqp.Connection, exchange, queueName, key string, simpleQueueType SimpleQueueType, codec Codec, handler func(Req) Resp) error {
+	chn, queue, err := DeclareAndBind(conn, exchange, queueName, key, simpleQueueType)
+	if err != nil {
+		return fmt.Errorf("failed to declare and bind: %w", err)
+	}
+
+	msgs, err := chn.Consume(queue.Name, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("failed to register consumer: %w", err)
+	}
+
+	go func() {
+		for msg := range msgs {
+			if msg.ReplyTo == "" {
+				fmt.Printf("dropping request without reply address\n")
+				msg.Nack(false, false)
+				continue
+			}
+
+			var req Req
+			if err := codec.Unmarshal(&msg, &req); err != nil {
+				fmt.Printf("failed to parse request: %v\n", err)
+				msg.Nack(false, false)
+				continue
+			}
+
+			reply := amqp.Publishing{CorrelationId: msg.CorrelationId}
+			if err := codec.Marshal(handler(req), &reply); err != nil {
+				fmt.Printf("failed to encode reply: %v\n", err)
+				msg.Nack(false, false)
+				continue
+			}
+
+			// Replies go through the default exchange straight to the caller
+			err := chn.PublishWithContext(context.Background(), "", msg.ReplyTo, false, false, reply)
+			if err != nil {
+				fmt.Printf("failed to publish reply: %v\n", err)
+				msg.Nack(false, true)
+				continue
+			}
+			msg.Ack(false)
+		}
+	}()
+
+	return nil
+}
+
+func newCorrelationID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate correlation id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,113 @@
+package ratelimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket that refills at rate tokens per second up to
+// burst tokens
+type Bucket struct {
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time
+	dropped int
+	mu      *sync.Mutex
+}
+
+func NewBucket(rate float64, burst int) *Bucket {
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+		mu:     &sync.Mutex{},
+	}
+}
+
+// Allow takes a token if one is available. A rate of zero or less disables
+// the limit.
+func (b *Bucket) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.rate <= 0 {
+		return true
+	}
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		b.dropped++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Dropped is the number of times Allow has refused a token
+func (b *Bucket) Dropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dropped
+}
+
+// Keyed keeps a separate bucket for every key, such as one per player
+type Keyed struct {
+	rate    float64
+	burst   int
+	buckets map[string]*Bucket
+	mu      *sync.Mutex
+}
+
+func NewKeyed(rate float64, burst int) *Keyed {
+	return &Keyed{
+		rate:    rate,
+		burst:   burst,
+		buckets: map[string]*Bucket{},
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (k *Keyed) Allow(key string) bool {
+	k.mu.Lock()
+	b, ok := k.buckets[key]
+	if !ok {
+		b = NewBucket(k.rate, k.burst)
+		k.buckets[key] = b
+	}
+	k.mu.Unlock()
+	return b.Allow()
+}
+
+type Count struct {
+	Key     string
+	Dropped int
+}
+
+// Dropped lists the keys that have been limited, worst offender first
+func (k *Keyed) Dropped() []Count {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	counts := []Count{}
+	for key, b := range k.buckets {
+		if dropped := b.Dropped(); dropped > 0 {
+			counts = append(counts, Count{Key: key, Dropped: dropped})
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Dropped != counts[j].Dropped {
+			return counts[i].Dropped > counts[j].Dropped
+		}
+		return counts[i].Key < counts[j].Key
+	})
+	return counts
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBucketAllow(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		burst   int
+		calls   int
+		allowed int
+	}{
+		{"within burst", 1, 5, 3, 3},
+		{"burst used up", 1, 5, 8, 5},
+		{"no burst", 1, 0, 3, 0},
+		{"disabled", 0, 0, 100, 100},
+		{"negative rate disables", -1, 1, 10, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBucket(tt.rate, tt.burst)
+			allowed := 0
+			for i := 0; i < tt.calls; i++ {
+				if b.Allow() {
+					allowed++
+				}
+			}
+			if allowed != tt.allowed {
+				t.Fatalf("allowed %d of %d, want %d", allowed, tt.calls, tt.allowed)
+			}
+			if b.Dropped() != tt.calls-tt.allowed {
+				t.Fatalf("Dropped() = %d, want %d", b.Dropped(), tt.calls-tt.allowed)
+			}
+		})
+	}
+}
+
+func TestBucketRefills(t *testing.T) {
+	b := NewBucket(2, 3)
+	for b.Allow() {
+	}
+
+	// Pretend a second and a half has passed: three tokens at 2/s, capped
+	// at the burst
+	b.last = b.last.Add(-1500 * time.Millisecond)
+	allowed := 0
+	for b.Allow() {
+		allowed++
+	}
+	if allowed != 3 {
+		t.Fatalf("allowed %d after refilling, want 3", allowed)
+	}
+
+	b.last = b.last.Add(-time.Hour)
+	allowed = 0
+	for b.Allow() {
+		allowed++
+	}
+	if allowed != 3 {
+		t.Fatalf("allowed %d after a long wait, want the burst of 3", allowed)
+	}
+}
+
+func TestKeyed(t *testing.T) {
+	k := NewKeyed(1, 2)
+	calls := []struct {
+		key  string
+		want bool
+	}{
+		{"alice", true},
+		{"alice", true},
+		{"alice", false},
+		{"bob", true},
+		{"alice", false},
+		{"bob", true},
+		{"bob", false},
+		{"carol", true},
+	}
+	for i, c := range calls {
+		if got := k.Allow(c.key); got != c.want {
+			t.Fatalf("call %d: Allow(%q) = %v, want %v", i, c.key, got, c.want)
+		}
+	}
+
+	want := []Count{{Key: "alice", Dropped: 2}, {Key: "bob", Dropped: 1}}
+	if got := k.Dropped(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Dropped() = %+v, want %+v", got, want)
+	}
+}
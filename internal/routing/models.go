@@ -1,6 +1,9 @@
 package routing
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type PlayingState struct {
 	IsPaused bool
@@ -11,3 +14,43 @@ type GameLog struct {
 	Message     string
 	Username    string
 }
+
+type LoginRequest struct {
+	Username string
+	Password string
+}
+
+type LoginResponse struct {
+	Token string
+	Error string
+}
+
+type LogoutRequest struct {
+	Username string
+	Token    string
+}
+
+type LogoutResponse struct {
+	Error string
+}
+
+// SessionEvent tells other server instances about a session starting or
+// ending. Only the token hash is shared. Servers sign events with a secret
+// only they know, since players can publish to the same exchange.
+type SessionEvent struct {
+	Username  string
+	TokenHash string
+	ExpiresAt time.Time
+	Active    bool
+	IssuedAt  time.Time
+	Signature string
+}
+
+type MapRequest struct{}
+
+// MapResponse carries a map in its file form, see gamelogic.ParseMap. It
+// answers MapKey requests and is broadcast on MapChangedKey.
+type MapResponse struct {
+	Name       string
+	Definition json.RawMessage
+}
@@ -5,12 +5,55 @@ const (
 
 	WarRecognitionsPrefix = "war"
 
+	WarResultsPrefix = "war_results"
+
+	TerritoryPrefix = "territory"
+
 	PauseKey = "pause"
 
 	GameLogSlug = "game_logs"
+
+	LoginKey = "login"
+
+	LogoutKey = "logout"
+
+	SessionsPrefix = "sessions"
+
+	MapKey = "map"
+
+	MapChangedKey = "map_changed"
+
+	TurnKey = "turn"
+
+	TurnOrderKey = "turn_order"
+
+	SpawnKey = "spawn"
+
+	ArmyKey = "army"
+
+	EconomyKey = "economy"
+
+	// LeaderKey is where server instances elect the one that runs the
+	// game's clocks
+	LeaderKey = "server_leader"
+
+	// VerifiedPrefix marks messages the server has checked and relayed.
+	// Players only bind to these, never to each other's raw messages.
+	VerifiedPrefix = "verified"
 )
 
-const (
+// VerifiedKey is the routing key the server relays a message published
+// with key on
+func VerifiedKey(key string) string {
+	return VerifiedPrefix + "." + key
+}
+
+// SessionTokenHeader carries a player's session token on messages the
+// server consumes
+const SessionTokenHeader = "x-peril-session"
+
+// Exchange names can be overridden from configuration at startup
+var (
 	ExchangePerilDirect     = "peril_direct"
 	ExchangePerilTopic      = "peril_topic"
 	ExchangePerilDeadLetter = "peril_dlx"
@@ -23,9 +23,12 @@ cleanup() {
 # Setup trap for SIGINT
 trap 'cleanup' SIGINT
 
+# Instances only share sessions signed with the same secret
+export PERIL_SESSION_SECRET="${PERIL_SESSION_SECRET:-$(head -c 32 /dev/urandom | od -An -tx1 | tr -d ' \n')}"
+
 # Start the specified number of instances of the program in the background
 for (( i=0; i<num_instances; i++ )); do
-  go run ./cmd/server &
+  PERIL_INSTANCE_ID="server-$i" go run ./cmd/server &
   pids+=($!)
 done
 
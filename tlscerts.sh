@@ -0,0 +1,29 @@
+#!/bin/bash
+
+# Generates a throwaway CA plus server and client certificates for trying
+# amqps:// locally with cmd/tlsproxy. Not for production use.
+
+set -e
+
+dir=${1:-certs}
+client=${2:-peril-client}
+mkdir -p "$dir"
+cd "$dir"
+
+openssl req -x509 -newkey rsa:2048 -nodes -days 365 \
+  -keyout ca.key -out ca.pem -subj "/CN=peril-test-ca"
+
+openssl req -newkey rsa:2048 -nodes \
+  -keyout server.key -out server.csr -subj "/CN=localhost"
+printf "subjectAltName=DNS:localhost,IP:127.0.0.1\n" > server.ext
+openssl x509 -req -in server.csr -CA ca.pem -CAkey ca.key -CAcreateserial \
+  -days 365 -out server.pem -extfile server.ext
+
+openssl req -newkey rsa:2048 -nodes \
+  -keyout client.key -out client.csr -subj "/CN=$client"
+printf "extendedKeyUsage=clientAuth\n" > client.ext
+openssl x509 -req -in client.csr -CA ca.pem -CAkey ca.key -CAcreateserial \
+  -days 365 -out client.pem -extfile client.ext
+
+rm -f server.csr client.csr server.ext client.ext ca.srl
+echo "Wrote ca.pem, server.pem/key and client.pem/key to $dir"